@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// startHTTPServer serves /metrics and the read-only JSON API on config.HTTPListen in the background
+func startHTTPServer(state *AppState, config *Config) {
+	if config.HTTPListen == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", state.exporter)
+	mux.HandleFunc("GET /entities", func(w http.ResponseWriter, r *http.Request) {
+		handleEntities(w, r, state, false)
+	})
+	mux.HandleFunc("GET /entities/{guid}", func(w http.ResponseWriter, r *http.Request) {
+		handleEntity(w, r, state)
+	})
+	mux.HandleFunc("GET /alerts", func(w http.ResponseWriter, r *http.Request) {
+		handleEntities(w, r, state, true)
+	})
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		handleHealth(w, state, config)
+	})
+
+	go func() {
+		logFor("api").Info("HTTP server listening", "addr", config.HTTPListen)
+		if err := http.ListenAndServe(config.HTTPListen, mux); err != nil {
+			logFor("api").Error("HTTP server stopped", "err", err)
+			if state.eventLog != nil {
+				writeEvent(state.eventLog, "error", "http_error", map[string]interface{}{"error": err.Error()})
+			}
+		}
+	}()
+}
+
+// snapshotRecords converts the current snapshot's entities into records
+func snapshotRecords(state *AppState) []EntityRecord {
+	entities := state.Snapshot().Entities
+	records := make([]EntityRecord, 0, len(entities))
+	for _, e := range entities {
+		records = append(records, toRecord(e))
+	}
+	return records
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		logFor("api").Debug("writing response failed", "err", err)
+	}
+}
+
+// handleEntities serves /entities and /alerts; ?name= filters by substring, ?alerting=true by state
+func handleEntities(w http.ResponseWriter, r *http.Request, state *AppState, alertsOnly bool) {
+	name := strings.ToLower(r.URL.Query().Get("name"))
+	alerting := alertsOnly || r.URL.Query().Get("alerting") == "true"
+
+	records := make([]EntityRecord, 0)
+	for _, rec := range snapshotRecords(state) {
+		if alerting && !rec.Alerting {
+			continue
+		}
+		if name != "" && !strings.Contains(strings.ToLower(rec.Name), name) {
+			continue
+		}
+		records = append(records, rec)
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+func handleEntity(w http.ResponseWriter, r *http.Request, state *AppState) {
+	guid := r.PathValue("guid")
+	for _, rec := range snapshotRecords(state) {
+		if rec.GUID == guid {
+			writeJSON(w, http.StatusOK, rec)
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": "entity not found"})
+}
+
+// handleHealth reports "ok", "stale" when refreshes have stopped or only cached data is available,
+// or "error" when the last fetch failed
+func handleHealth(w http.ResponseWriter, state *AppState, config *Config) {
+	snap := state.Snapshot()
+	lastRefresh := snap.RefreshedAt
+	errMsg := snap.Error
+	count := len(snap.Entities)
+	stale := snap.Stale
+
+	age := time.Since(lastRefresh)
+	status, code := "ok", http.StatusOK
+	switch {
+	case errMsg != "":
+		status, code = "error", http.StatusServiceUnavailable
+	case stale || age > 3*time.Duration(config.RefreshInterval)*time.Second:
+		status, code = "stale", http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, code, map[string]interface{}{
+		"status":       status,
+		"error":        errMsg,
+		"entities":     count,
+		"last_refresh": lastRefresh.UTC().Format(time.RFC3339),
+		"age_seconds":  int(age.Seconds()),
+		"cached":       stale,
+	})
+}
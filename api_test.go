@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIAfterFailedFetchWithoutCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg := defaultConfig()
+	cfg.APIKey, cfg.AccountID = "", ""
+
+	state := newAppState(cfg)
+	refreshEntities(context.Background(), state, cfg, true, func() {})
+
+	for _, alertsOnly := range []bool{false, true} {
+		rec := httptest.NewRecorder()
+		handleEntities(rec, httptest.NewRequest(http.MethodGet, "/entities", nil), state, alertsOnly)
+		if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+			t.Errorf("alertsOnly=%v: got %d %s, want 200 []", alertsOnly, rec.Code, rec.Body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handleHealth(rec, state, cfg)
+	var health map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatalf("health body %q: %v", rec.Body, err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || health["status"] != "error" || health["entities"] != float64(0) {
+		t.Errorf("health = %d %v, want 503 with status error and no entities", rec.Code, health)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// EntityCache is the last successful snapshot for one account, stored under ~/.osiris/cache
+type EntityCache struct {
+	AccountID string    `json:"account_id"`
+	SavedAt   time.Time `json:"saved_at"`
+	Entities  []*Entity `json:"entities"`
+}
+
+// cachePath returns the cache file for the configured account
+func cachePath(config *Config) string {
+	account := config.AccountID
+	if account == "" {
+		account = "default"
+	}
+	return filepath.Join(getOsirisPath("cache"), filepath.Base(account)+".json")
+}
+
+// saveCache stores a fully refreshed entity list; demo data is never cached
+func saveCache(config *Config, entities []*Entity) error {
+	if config.Demo {
+		return nil
+	}
+	path := cachePath(config)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(EntityCache{AccountID: config.AccountID, SavedAt: time.Now(), Entities: entities})
+	if err != nil {
+		return err
+	}
+	// Write then rename so a crash never leaves a truncated cache behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadCache reads the cached snapshot for the configured account, applying the current filter
+func loadCache(config *Config) (*EntityCache, error) {
+	if config.Demo {
+		return nil, fmt.Errorf("demo mode")
+	}
+	data, err := os.ReadFile(cachePath(config))
+	if err != nil {
+		return nil, err
+	}
+	var cache EntityCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("corrupt cache: %w", err)
+	}
+	if cache.AccountID != config.AccountID {
+		return nil, fmt.Errorf("cache is for account %s", cache.AccountID)
+	}
+	redactor.AddHostNames(cache.Entities)
+	cache.Entities = applyView(filterEntities(cache.Entities, config.Filter), config.View)
+	logFor("cache").Info("loaded cached entities", "entities", len(cache.Entities), "saved_at", cache.SavedAt.Format(time.RFC3339))
+	return &cache, nil
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Exit codes for one-shot commands, so scripts and cron checks can branch on alert presence
+const (
+	exitOK       = 0 // no alerting entities in the result
+	exitAlerting = 1 // at least one entity in the result is alerting
+	exitError    = 2 // usage error, or alert state could not be determined
+)
+
+// EntityRecord is the flat, serialisable view of an entity used by CLI output and the HTTP API
+type EntityRecord struct {
+	Name      string            `json:"name"`
+	GUID      string            `json:"guid"`
+	Type      string            `json:"type"`
+	Alerting  bool              `json:"alerting"`
+	Severity  string            `json:"severity,omitempty"`
+	Condition string            `json:"condition,omitempty"`
+	Message   string            `json:"message,omitempty"`
+	Flapping  bool              `json:"flapping"`
+	Reporting bool              `json:"reporting"`
+	OS        string            `json:"os,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+func toRecord(e *Entity) EntityRecord {
+	return EntityRecord{
+		Name:      e.Name,
+		GUID:      e.GUID,
+		Type:      e.Type,
+		Alerting:  e.HasAlert,
+		Severity:  e.Severity,
+		Condition: e.AlertType,
+		Message:   e.AlertMessage,
+		Flapping:  e.Flapping,
+		Reporting: !e.NotReporting,
+		OS:        e.OS,
+		Tags:      e.Tags,
+	}
+}
+
+// isCommand reports whether args start with a one-shot subcommand
+func isCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "list", "alerts", "show", "ssh", "export":
+		return true
+	}
+	return false
+}
+
+// runCommand executes a one-shot subcommand and returns the process exit code
+func runCommand(config *Config, args []string) int {
+	switch args[0] {
+	case "list":
+		return cmdList(config, args[1:], false)
+	case "alerts":
+		return cmdList(config, args[1:], true)
+	case "show":
+		return cmdShow(config, args[1:])
+	case "ssh":
+		return cmdSSH(config, args[1:])
+	case "export":
+		return cmdExport(config, args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+	return exitError
+}
+
+// fetchSnapshot does a full synchronous refresh: entities, then incidents, sorted alerting first.
+// When New Relic is unreachable it falls back to the on-disk cache, and fails without one:
+// scripts must never see the placeholder hosts the UI shows before it is configured.
+func fetchSnapshot(config *Config) ([]*Entity, bool, error) {
+	result := FetchEntities(context.Background(), config)
+	incidentsOK := false
+	if result.Error != "" {
+		cache, err := loadCache(config)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s (no cached data to fall back on)", result.Error)
+		}
+		fmt.Fprintf(os.Stderr, "osiris: %s (showing cached data from %s)\n", result.Error, cache.SavedAt.Format(time.RFC3339))
+		return cache.Entities, false, nil
+	}
+	if incidentsOK = fetchIncidents(context.Background(), config, result); !incidentsOK {
+		fmt.Fprintln(os.Stderr, "osiris: could not fetch alert state; alerting flags may be incomplete")
+	}
+	entities := applyView(filterEntities(result.Entities, config.Filter), config.View)
+	sort.SliceStable(entities, func(i, j int) bool {
+		if entities[i].HasAlert == entities[j].HasAlert {
+			return entities[i].Name < entities[j].Name
+		}
+		return entities[i].HasAlert
+	})
+	return entities, incidentsOK, nil
+}
+
+// resultCode maps a command's entities to its exit code
+func resultCode(entities []*Entity, incidentsOK bool) int {
+	if !incidentsOK {
+		return exitError
+	}
+	for _, e := range entities {
+		if e.HasAlert {
+			return exitAlerting
+		}
+	}
+	return exitOK
+}
+
+func cmdList(config *Config, args []string, alertsOnly bool) int {
+	name := "list"
+	if alertsOnly {
+		name = "alerts"
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	output := fs.String("o", "table", "output format: table, json, csv or markdown")
+	match := fs.String("name", "", "only entities whose name contains this text")
+	severity := fs.String("severity", "", "only alerting entities with this severity (critical, warning)")
+	entityType := fs.String("type", "", "only entities of this type (e.g. HOST)")
+	alerting := fs.Bool("alerting", alertsOnly, "only alerting entities")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if !validOutput(*output) {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", *output)
+		return exitError
+	}
+
+	entities, incidentsOK, err := fetchSnapshot(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "osiris: "+err.Error())
+		return exitError
+	}
+	filtered := make([]*Entity, 0, len(entities))
+	for _, e := range entities {
+		if *match != "" && !strings.Contains(strings.ToLower(e.Name), strings.ToLower(*match)) {
+			continue
+		}
+		if *alerting && !e.HasAlert {
+			continue
+		}
+		if *severity != "" && !strings.EqualFold(severityLabel(e), *severity) {
+			continue
+		}
+		if *entityType != "" && !strings.EqualFold(e.Type, *entityType) {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+
+	if err := writeEntities(os.Stdout, *output, filtered); err != nil {
+		fmt.Fprintln(os.Stderr, "osiris: "+err.Error())
+		return exitError
+	}
+	return resultCode(filtered, incidentsOK)
+}
+
+func cmdShow(config *Config, args []string) int {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	output := fs.String("o", "table", "output format: table, json, csv or markdown")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: osiris show [-o table|json|csv|markdown] <name>")
+		return exitError
+	}
+	if !validOutput(*output) {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", *output)
+		return exitError
+	}
+
+	entities, incidentsOK, err := fetchSnapshot(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "osiris: "+err.Error())
+		return exitError
+	}
+	entity, err := findEntity(entities, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "osiris: "+err.Error())
+		return exitError
+	}
+
+	switch *output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		err = enc.Encode(toRecord(entity))
+	case "csv":
+		err = writeEntities(os.Stdout, "csv", []*Entity{entity})
+	default:
+		r := toRecord(entity)
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Name:\t%s\n", r.Name)
+		fmt.Fprintf(tw, "GUID:\t%s\n", r.GUID)
+		fmt.Fprintf(tw, "Type:\t%s\n", r.Type)
+		fmt.Fprintf(tw, "Status:\t%s\n", statusLabel(entity))
+		if r.Alerting {
+			fmt.Fprintf(tw, "Severity:\t%s\n", severityLabel(entity))
+			fmt.Fprintf(tw, "Condition:\t%s\n", r.Condition)
+			fmt.Fprintf(tw, "Message:\t%s\n", r.Message)
+		}
+		err = tw.Flush()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "osiris: "+err.Error())
+		return exitError
+	}
+	return resultCode([]*Entity{entity}, incidentsOK)
+}
+
+func cmdSSH(config *Config, args []string) int {
+	fs := flag.NewFlagSet("ssh", flag.ContinueOnError)
+	user := fs.String("u", "", "ssh username (defaults to your ssh config)")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: osiris ssh [-u user] <name> [ssh args...]")
+		return exitError
+	}
+
+	// Only the entity list is needed to resolve the name; never connect to a demo host
+	result := FetchEntities(context.Background(), config)
+	entities := result.Entities
+	if result.Error != "" {
+		cache, err := loadCache(config)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "osiris: "+result.Error)
+			return exitError
+		}
+		fmt.Fprintf(os.Stderr, "osiris: %s (using cached data from %s)\n", result.Error, cache.SavedAt.Format(time.RFC3339))
+		entities = cache.Entities
+	}
+	entity, err := findEntity(entities, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "osiris: "+err.Error())
+		return exitError
+	}
+
+	target := entity.Name
+	if *user != "" {
+		target = *user + "@" + entity.Name
+	}
+	execCmd := exec.Command("ssh", append([]string{target}, fs.Args()[1:]...)...)
+	execCmd.Stdin = os.Stdin
+	execCmd.Stdout = os.Stdout
+	execCmd.Stderr = os.Stderr
+	if err := execCmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintln(os.Stderr, "osiris: "+err.Error())
+		return exitError
+	}
+	return exitOK
+}
+
+// cmdConfig implements "config check [path]": it loads the config the way startup does and
+// reports every problem with its line number, exiting non-zero if any would stop Osiris starting
+func cmdConfig(config *Config, args []string) int {
+	if len(args) == 0 || args[0] != "check" || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: osiris config check [path]")
+		return exitError
+	}
+	if len(args) == 2 {
+		config = LoadConfig(args[1])
+		if config.Path == "" {
+			fmt.Fprintf(os.Stderr, "osiris: %s: no such file\n", args[1])
+			return exitError
+		}
+	}
+	if config.Path == "" {
+		fmt.Println("no config file found; Osiris runs on defaults")
+		return exitOK
+	}
+
+	for _, warning := range config.Warnings {
+		fmt.Println("warning: " + warning)
+	}
+	errs := config.Errors
+	if len(errs) == 0 {
+		// Also catch a default profile, account or view that doesn't resolve
+		if err := (&Options{}).Apply(config); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	for _, msg := range errs {
+		fmt.Println("error: " + msg)
+	}
+	if len(errs) > 0 {
+		fmt.Printf("%s: %d error(s)\n", config.Path, len(errs))
+		return exitError
+	}
+	fmt.Printf("%s: ok (%d accounts, %d profiles, %d views)\n", config.Path, len(config.Accounts), len(config.Profiles), len(config.Views))
+	return exitOK
+}
+
+// findEntity resolves a name exactly (case-insensitive) or by unique substring
+func findEntity(entities []*Entity, name string) (*Entity, error) {
+	matches := make([]*Entity, 0)
+	for _, e := range entities {
+		if strings.EqualFold(e.Name, name) {
+			return e, nil
+		}
+		if strings.Contains(strings.ToLower(e.Name), strings.ToLower(name)) {
+			matches = append(matches, e)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no entity matches %q", name)
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, 0, len(matches))
+	for _, e := range matches {
+		names = append(names, e.Name)
+	}
+	return nil, fmt.Errorf("%q is ambiguous: %s", name, strings.Join(names, ", "))
+}
+
+func validOutput(format string) bool {
+	return format == "table" || format == "json" || format == "csv" || format == "markdown"
+}
+
+// markdownCell escapes text for a single Markdown table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+func statusLabel(e *Entity) string {
+	status := "OK"
+	if e.HasAlert {
+		status = "ALERT"
+	}
+	if e.Flapping {
+		status += " (flapping)"
+	}
+	return status
+}
+
+// writeEntities renders entities as a table, a JSON array, CSV with a header row or a Markdown table
+func writeEntities(w io.Writer, format string, entities []*Entity) error {
+	switch format {
+	case "json":
+		records := make([]EntityRecord, 0, len(entities))
+		for _, e := range entities {
+			records = append(records, toRecord(e))
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(records)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"name", "guid", "type", "alerting", "severity", "condition", "message", "flapping"})
+		for _, e := range entities {
+			r := toRecord(e)
+			cw.Write([]string{r.Name, r.GUID, r.Type, fmt.Sprint(r.Alerting), r.Severity, r.Condition, r.Message, fmt.Sprint(r.Flapping)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "markdown":
+		fmt.Fprintln(w, "| Name | Status | Severity | Condition | Message |")
+		fmt.Fprintln(w, "|------|--------|----------|-----------|---------|")
+		for _, e := range entities {
+			severity, condition, message := "", "", ""
+			if e.HasAlert {
+				severity, condition, message = severityLabel(e), e.AlertType, e.AlertMessage
+			}
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n", markdownCell(e.Name), statusLabel(e),
+				severity, markdownCell(condition), markdownCell(message))
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATUS\tSEVERITY\tCONDITION")
+	for _, e := range entities {
+		severity, condition := "-", "-"
+		if e.HasAlert {
+			severity = severityLabel(e)
+			condition = e.AlertType
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Name, statusLabel(e), severity, condition)
+	}
+	return tw.Flush()
+}
@@ -3,97 +3,516 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
 
-var DebugEnabled bool = false
+type Config struct {
+	Path             string // file the config was read from, "" when running on defaults
+	APIKey           string
+	APIKeyCmd        string // command whose output is the API key
+	APIKeyEnv        string // environment variable holding the API key
+	APIKeyKeyring    bool   // read the API key from the Secret Service keyring
+	AccountID        string
+	Region           string // "us" or "eu", which New Relic API hosts to use
+	Account          string // selected [accounts] entry, applied over the top-level settings
+	Profile          string // selected [profiles] entry
+	RefreshInterval  int
+	HistoryDays      int
+	FlapThreshold    int
+	FlapWindow       int // minutes
+	NotifyMethods    []string
+	NotifyCommand    string
+	NotifySeverities []string
+	NotifyCooldown   int // seconds
+	Webhooks         []Webhook
+	WebhookTemplate  string
+	WebhookRetries   int
+	Filter           string
+	View             string
+	Demo             bool
+	LogLevel         string
+	LogFile          string
+	LogMaxSize       int // megabytes before the log file is rotated
+	HTTPListen       string
+	ExportDir        string
+	ExportFormat     string
+	ExportClipboard  bool
+	ExportOnChange   bool
+	FetchConcurrency int  // parallel NerdGraph requests per refresh
+	AdaptiveRefresh  bool // vary the refresh interval with alert activity, idleness and errors
+	RedactHosts      bool // mask host names and IPs in logs and exports, not just secrets
+	RedactPatterns   []string
+	Theme            string
 
-func debugLog(msg string) {
-	if !DebugEnabled {
-		return
-	}
-	var logPath string
-	if home, err := os.UserHomeDir(); err == nil {
-		logPath = filepath.Join(home, ".osiris", "debug.log")
-	} else {
-		logPath = ".osiris/debug.log"
-	}
-	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return
+	// Named sections, only available in config.toml
+	Accounts map[string][]setting
+	Profiles map[string][]setting
+	Views    map[string]NamedView
+	Themes   map[string]Theme
+	Keys     map[string]rune // action -> key
+
+	Warnings []string // problems found while loading, shown at startup
+	Errors   []string // invalid settings; Osiris refuses to start until they are fixed
+}
+
+// NamedView is a [views] entry: a saved filter plus one of the built-in views
+type NamedView struct {
+	Filter string
+	Show   string
+}
+
+// setting is one key = value kept for later, so profiles and accounts can be applied once
+// the command line has picked one
+type setting struct {
+	key    string
+	values []string // several for repeatable keys such as webhook
+	line   int
+}
+
+type settingKind int
+
+const (
+	kindString settingKind = iota
+	kindInt
+	kindBool
+	kindID       // account IDs, which may be written as a number or a string
+	kindList     // comma-separated in the key=value format, an array in config.toml
+	kindRepeated // repeated in the key=value format, an array in config.toml
+)
+
+func (k settingKind) String() string {
+	switch k {
+	case kindInt:
+		return "an integer"
+	case kindBool:
+		return "true or false"
+	case kindID:
+		return "a number or string"
+	case kindList, kindRepeated:
+		return "a string or an array of strings"
 	}
-	defer f.Close()
-	fmt.Fprintf(f, "%s\n", msg)
+	return "a string"
 }
 
-type Config struct {
-	APIKey          string
-	AccountID       string
-	RefreshInterval int
+// settingKinds lists every top-level setting, which profiles may also set
+var settingKinds = map[string]settingKind{
+	"api_key":           kindString,
+	"api_key_cmd":       kindString,
+	"api_key_env":       kindString,
+	"api_key_keyring":   kindBool,
+	"account_id":        kindID,
+	"region":            kindString,
+	"account":           kindString,
+	"profile":           kindString,
+	"refresh_interval":  kindInt,
+	"adaptive_refresh":  kindBool,
+	"fetch_concurrency": kindInt,
+	"history_days":      kindInt,
+	"flap_threshold":    kindInt,
+	"flap_window":       kindInt,
+	"notify":            kindList,
+	"notify_command":    kindString,
+	"notify_severities": kindList,
+	"notify_cooldown":   kindInt,
+	"webhook":           kindRepeated,
+	"webhook_template":  kindString,
+	"webhook_retries":   kindInt,
+	"filter":            kindString,
+	"view":              kindString,
+	"theme":             kindString,
+	"demo":              kindBool,
+	"export_dir":        kindString,
+	"export_format":     kindString,
+	"export_clipboard":  kindBool,
+	"export_on_change":  kindBool,
+	"http_listen":       kindString,
+	"log_file":          kindString,
+	"log_max_size":      kindInt,
+	"log_level":         kindString,
+	"redact_hosts":      kindBool,
+	"redact_pattern":    kindRepeated,
 }
 
-func LoadConfig() *Config {
+// accountSettings may appear in an [accounts.NAME] table
+var accountSettings = []string{"account_id", "region", "api_key", "api_key_cmd", "api_key_env", "api_key_keyring"}
+
+// validRegions are the New Relic data centers accepted by region=
+var validRegions = []string{"us", "eu"}
+
+// defaultConfig returns the settings used when the config file doesn't say otherwise
+func defaultConfig() *Config {
 	cfg := &Config{
-		RefreshInterval: 30,
+		RefreshInterval:  30,
+		HistoryDays:      7,
+		FlapThreshold:    4,
+		FlapWindow:       60,
+		NotifyMethods:    []string{"bell"},
+		NotifyCooldown:   300,
+		WebhookRetries:   3,
+		View:             "all",
+		Region:           "us",
+		LogLevel:         "off",
+		LogFile:          getOsirisPath("debug.log"),
+		LogMaxSize:       5,
+		ExportDir:        getOsirisPath("exports"),
+		ExportFormat:     "csv",
+		FetchConcurrency: 4,
+		AdaptiveRefresh:  true,
+		Theme:            "default",
+		Accounts:         make(map[string][]setting),
+		Profiles:         make(map[string][]setting),
+		Views:            make(map[string]NamedView),
+		Themes:           make(map[string]Theme),
+		Keys:             defaultKeybindings(),
+	}
+	for name, t := range builtinThemes {
+		cfg.Themes[name] = t
+	}
+	return cfg
+}
+
+// LoadConfig reads the config file at configPath, or the default location when it is empty.
+// A path ending in .toml is read as config.toml, anything else as the key=value format.
+func LoadConfig(configPath string) *Config {
+	cfg := defaultConfig()
+
+	if configPath == "" {
+		configPath = getConfigPath()
 	}
+	logFor("config").Debug("loading config", "path", configPath)
 
-	configPath := getConfigPath()
-	debugLog("Loading config from: " + configPath)
-	
 	file, err := os.Open(configPath)
 	if err != nil {
 		// Try with .txt extension (Windows compatibility)
 		configPathTxt := configPath + ".txt"
-		debugLog("Config not found at: " + configPath + ", trying: " + configPathTxt)
+		logFor("config").Debug("config not found, trying .txt", "path", configPath)
 		file, err = os.Open(configPathTxt)
 		if err != nil {
-			debugLog("Config not found at: " + configPathTxt)
+			logFor("config").Info("no config file, using defaults", "path", configPath)
 			return cfg
 		}
 	}
 	defer file.Close()
+	cfg.Path = file.Name()
+	if warning := configPermissionWarning(file.Name()); warning != "" {
+		cfg.Warnings = append(cfg.Warnings, warning)
+	}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		cfg.Errors = append(cfg.Errors, fmt.Sprintf("%s: %v", cfg.Path, err))
+		return cfg
+	}
+	if strings.HasSuffix(strings.TrimSuffix(cfg.Path, ".txt"), ".toml") {
+		for _, err := range loadTOMLConfig(cfg, cfg.Path, string(data)) {
+			cfg.Errors = append(cfg.Errors, err.Error())
+		}
+	} else {
+		// The original format predates validation, so its problems are only warnings
+		for _, err := range loadKeyValueConfig(cfg, cfg.Path, string(data)) {
+			cfg.Warnings = append(cfg.Warnings, err.Error())
+		}
+	}
+	return cfg
+}
+
+// loadKeyValueConfig reads the original key=value format, one setting per line
+func loadKeyValueConfig(cfg *Config, path, data string) []error {
+	var errs []error
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
+		fail := func(format string, args ...any) {
+			errs = append(errs, &ConfigError{Path: path, Line: lineNo, Msg: fmt.Sprintf(format, args...)})
+		}
 
 		parts := strings.SplitN(line, "=", 2)
 		if len(parts) != 2 {
+			fail("expected key = value")
 			continue
 		}
-
 		key := strings.TrimSpace(parts[0])
 		value := strings.TrimSpace(parts[1])
+		if _, ok := settingKinds[key]; !ok {
+			fail("unknown setting %q", key)
+			continue
+		}
+		if err := applySetting(cfg, key, value); err != nil {
+			fail("%s: %v", key, err)
+		}
+	}
+	return errs
+}
 
-		switch key {
-		case "api_key":
-			cfg.APIKey = value
-			debugLog("Loaded API key")
-		case "account_id":
-			cfg.AccountID = value
-			debugLog("Loaded account ID")
-		case "refresh_interval":
-			if interval, err := strconv.Atoi(value); err == nil {
-				cfg.RefreshInterval = interval
+// applySetting validates one setting and stores it in cfg. Repeatable settings such as
+// webhook are appended to.
+func applySetting(cfg *Config, key, value string) error {
+	var err error
+	switch key {
+	case "api_key":
+		cfg.APIKey = value
+		logFor("config").Debug("loaded API key")
+	case "api_key_cmd":
+		cfg.APIKeyCmd = value
+	case "api_key_env":
+		cfg.APIKeyEnv = value
+	case "api_key_keyring":
+		err = setBool(&cfg.APIKeyKeyring, value)
+	case "account_id":
+		for _, id := range splitList(value) {
+			if n, err := strconv.Atoi(id); err != nil || n < 1 {
+				return fmt.Errorf("invalid account ID %q (want a number, or several separated by commas)", id)
 			}
 		}
+		cfg.AccountID = value
+		logFor("config").Debug("loaded account ID")
+	case "region":
+		value = strings.ToLower(value)
+		if !contains(validRegions, value) {
+			return fmt.Errorf("unknown region %q (want %s)", value, strings.Join(validRegions, ", "))
+		}
+		cfg.Region = value
+	case "account":
+		if _, ok := cfg.Accounts[value]; !ok {
+			return fmt.Errorf("no [accounts.%s] table", value)
+		}
+		cfg.Account = value
+	case "profile":
+		if _, ok := cfg.Profiles[value]; !ok {
+			return fmt.Errorf("no [profiles.%s] table", value)
+		}
+		cfg.Profile = value
+	case "refresh_interval":
+		err = setInt(&cfg.RefreshInterval, value, 1)
+	case "redact_hosts":
+		err = setBool(&cfg.RedactHosts, value)
+	case "redact_pattern":
+		// May be repeated; each is a regular expression to mask
+		if _, err := regexp.Compile(value); err != nil {
+			return err
+		}
+		cfg.RedactPatterns = append(cfg.RedactPatterns, value)
+	case "adaptive_refresh":
+		err = setBool(&cfg.AdaptiveRefresh, value)
+	case "history_days":
+		err = setInt(&cfg.HistoryDays, value, 1)
+	case "fetch_concurrency":
+		err = setInt(&cfg.FetchConcurrency, value, 1)
+	case "flap_threshold":
+		err = setInt(&cfg.FlapThreshold, value, 1)
+	case "flap_window":
+		err = setInt(&cfg.FlapWindow, value, 1)
+	case "notify":
+		cfg.NotifyMethods = splitList(value)
+	case "notify_command":
+		cfg.NotifyCommand = value
+	case "notify_severities":
+		cfg.NotifySeverities = splitList(strings.ToLower(value))
+	case "notify_cooldown":
+		err = setInt(&cfg.NotifyCooldown, value, 0)
+	case "webhook":
+		// May be repeated to post to several destinations
+		hook, err := parseWebhook(value)
+		if err != nil {
+			return err
+		}
+		cfg.Webhooks = append(cfg.Webhooks, hook)
+	case "webhook_template":
+		cfg.WebhookTemplate = value
+	case "webhook_retries":
+		err = setInt(&cfg.WebhookRetries, value, 0)
+	case "filter":
+		cfg.Filter = value
+	case "view":
+		if _, ok := cfg.Views[value]; !ok && !contains(validViews, value) {
+			return fmt.Errorf("unknown view %q (want %s, or a [views] name)", value, strings.Join(validViews, ", "))
+		}
+		cfg.View = value
+	case "theme":
+		if _, ok := cfg.Themes[value]; !ok {
+			return fmt.Errorf("unknown theme %q (want %s, or a [themes] name)", value, strings.Join(sortedKeys(builtinThemes), ", "))
+		}
+		cfg.Theme = value
+	case "demo":
+		err = setBool(&cfg.Demo, value)
+	case "export_dir":
+		cfg.ExportDir = expandHome(value)
+	case "export_format":
+		if _, ok := exportExtensions[value]; !ok {
+			return fmt.Errorf("unknown format %q (want %s)", value, strings.Join(sortedKeys(exportExtensions), ", "))
+		}
+		cfg.ExportFormat = value
+	case "export_clipboard":
+		err = setBool(&cfg.ExportClipboard, value)
+	case "export_on_change":
+		err = setBool(&cfg.ExportOnChange, value)
+	case "http_listen":
+		cfg.HTTPListen = value
+	case "log_file":
+		cfg.LogFile = expandHome(value)
+	case "log_max_size":
+		err = setInt(&cfg.LogMaxSize, value, 1)
+	case "log_level":
+		if !contains(validLogLevels, value) {
+			return fmt.Errorf("unknown level %q (want %s)", value, strings.Join(validLogLevels, ", "))
+		}
+		cfg.LogLevel = value
+	default:
+		return fmt.Errorf("unknown setting %q", key)
 	}
+	return err
+}
 
-	return cfg
+// useProfile applies a [profiles] entry over the top-level settings
+func (cfg *Config) useProfile(name string) error {
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q (have %s)", name, strings.Join(sortedKeys(cfg.Profiles), ", "))
+	}
+	cfg.Profile = name
+	return cfg.applySettings(profile)
+}
+
+// resolve applies the selected account and expands a named view into its filter and built-in view
+func (cfg *Config) resolve() error {
+	if cfg.Account != "" {
+		account, ok := cfg.Accounts[cfg.Account]
+		if !ok {
+			return fmt.Errorf("unknown account %q", cfg.Account)
+		}
+		// An account with its own credentials replaces the top-level ones rather than mixing with them
+		for _, s := range account {
+			if strings.HasPrefix(s.key, "api_key") {
+				cfg.APIKey, cfg.APIKeyCmd, cfg.APIKeyEnv, cfg.APIKeyKeyring = "", "", "", false
+				break
+			}
+		}
+		if err := cfg.applySettings(account); err != nil {
+			return err
+		}
+	}
+
+	if !contains(validViews, cfg.View) {
+		view, ok := cfg.Views[cfg.View]
+		if !ok {
+			return fmt.Errorf("unknown view %q (want %s, or a [views] name)", cfg.View, strings.Join(validViews, ", "))
+		}
+		cfg.View = view.Show
+		if view.Filter != "" {
+			cfg.Filter = view.Filter
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) applySettings(settings []setting) error {
+	for _, s := range settings {
+		if err := s.applyTo(cfg); err != nil {
+			return fmt.Errorf("%s:%d: %s: %v", cfg.Path, s.line, s.key, err)
+		}
+	}
+	return nil
+}
+
+// accountIDs returns the accounts whose hosts are listed; NRQL panels query the first one
+func accountIDs(cfg *Config) []string {
+	return splitList(cfg.AccountID)
+}
+
+// parseBool accepts the usual spellings of true and false in config values
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "1", "yes", "on":
+		return true, nil
+	case "false", "0", "no", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("want true or false, got %q", value)
+}
+
+// setBool and setInt leave the current value alone when value is invalid
+func setBool(dst *bool, value string) error {
+	b, err := parseBool(value)
+	if err == nil {
+		*dst = b
+	}
+	return err
+}
+
+// setInt requires a whole number of at least min
+func setInt(dst *int, value string, min int) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min {
+		return fmt.Errorf("want a whole number of at least %d, got %q", min, value)
+	}
+	*dst = n
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expandHome replaces a leading ~/ with the user's home directory
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+// splitList parses a comma-separated config value, dropping empty items
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getOsirisPath returns the path of a file under the ~/.osiris directory; callers that write
+// there create the directory themselves
+func getOsirisPath(name string) string {
+	dir := ".osiris"
+	if home, err := os.UserHomeDir(); err == nil {
+		dir = filepath.Join(home, ".osiris")
+	}
+	return filepath.Join(dir, name)
 }
 
 func getConfigPath() string {
 	// Windows: %APPDATA%\.osiris\config
 	// Linux/Mac: ~/.osiris/config
+	// config.toml in the same directory takes precedence when it exists
+	configDir := ".osiris"
 	if home, err := os.UserHomeDir(); err == nil {
-		configDir := filepath.Join(home, ".osiris")
-		return filepath.Join(configDir, "config")
+		configDir = filepath.Join(home, ".osiris")
 	}
-	return ".osiris/config"
+	if tomlPath := filepath.Join(configDir, "config.toml"); fileExists(tomlPath) {
+		return tomlPath
+	}
+	return filepath.Join(configDir, "config")
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
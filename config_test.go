@@ -0,0 +1,314 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testTOMLConfig = `api_key = "NRAK-TOP"
+account = "prod"
+refresh_interval = 60
+notify = ["bell", "desktop"]
+view = "web"
+theme = "dark"
+
+[accounts.prod]
+account_id = 1234567
+
+[accounts.eu]
+account_id = "7654321"
+region = "eu"
+api_key_env = "NR_EU_KEY"
+
+[profiles.oncall]
+account = "eu"
+refresh_interval = 15
+notify = ["bell"]
+
+[views.web]
+filter = "web-"
+show = "alerting"
+
+[themes.dark]
+ok = "#00ff00"
+alert = "fuchsia"
+
+[keybindings]
+quit = "x"
+refresh = "space"
+`
+
+func TestLoadTOMLConfig(t *testing.T) {
+	cfg := defaultConfig()
+	if errs := loadTOMLConfig(cfg, "config.toml", testTOMLConfig); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	if cfg.APIKey != "NRAK-TOP" || cfg.RefreshInterval != 60 || cfg.Account != "prod" || cfg.View != "web" || cfg.Theme != "dark" {
+		t.Errorf("top-level settings not applied: %+v", cfg)
+	}
+	if got := strings.Join(cfg.NotifyMethods, ","); got != "bell,desktop" {
+		t.Errorf("notify = %q, want bell,desktop", got)
+	}
+	if len(cfg.Accounts) != 2 || len(cfg.Profiles["oncall"]) != 3 {
+		t.Errorf("accounts = %v, profiles = %v", cfg.Accounts, cfg.Profiles)
+	}
+	if v := cfg.Views["web"]; v.Filter != "web-" || v.Show != "alerting" {
+		t.Errorf("views.web = %+v", v)
+	}
+	if th := cfg.Themes["dark"]; th.OK != "#00ff00" || th.Alert != "fuchsia" || th.NotReporting != builtinThemes["default"].NotReporting {
+		t.Errorf("themes.dark = %+v, want unset colors from the default theme", th)
+	}
+	if cfg.Keys["quit"] != 'x' || cfg.Keys["refresh"] != ' ' || cfg.Keys["search"] != defaultKeybindings()["search"] {
+		t.Errorf("keys = %v", cfg.Keys)
+	}
+}
+
+func TestLoadConfigCreatesNoFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if errs := loadTOMLConfig(defaultConfig(), "config.toml", testTOMLConfig); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".osiris")); !os.IsNotExist(err) {
+		t.Errorf("reading a config created ~/.osiris (stat err %v)", err)
+	}
+}
+
+func TestLoadTOMLConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string // path:line: message prefixes, in order
+	}{
+		{
+			name: "unknown setting",
+			src:  "refresh = 30\n",
+			want: []string{`config.toml:1: unknown setting "refresh"`},
+		},
+		{
+			name: "wrong type",
+			src:  "refresh_interval = \"30\"\nadaptive_refresh = 1\n",
+			want: []string{
+				"config.toml:1: refresh_interval must be an integer, not a string",
+				"config.toml:2: adaptive_refresh must be true or false, not an integer",
+			},
+		},
+		{
+			name: "out of range",
+			src:  "\nrefresh_interval = 0\n",
+			want: []string{"config.toml:2: refresh_interval: want a whole number of at least 1"},
+		},
+		{
+			name: "array of the wrong type",
+			src:  "notify = [1, 2]\n",
+			want: []string{"config.toml:1: notify must be a string or an array of strings, not an array containing an integer"},
+		},
+		{
+			name: "invalid values",
+			src:  "region = \"ap\"\nlog_level = \"loud\"\nredact_pattern = [\"(\"]\naccount_id = \"prod\"\n",
+			want: []string{
+				`config.toml:1: region: unknown region "ap"`,
+				`config.toml:2: log_level: unknown level "loud"`,
+				"config.toml:3: redact_pattern: error parsing regexp",
+				`config.toml:4: account_id: invalid account ID "prod"`,
+			},
+		},
+		{
+			name: "unknown table",
+			src:  "[colors]\nok = \"green\"\n",
+			want: []string{"config.toml:1: unknown table [colors]"},
+		},
+		{
+			name: "setting outside a named table",
+			src:  "[accounts]\naccount_id = 1\n",
+			want: []string{`config.toml:2: "account_id" must be inside a [accounts.NAME] table`},
+		},
+		{
+			name: "account without an ID",
+			src:  "[accounts.prod]\nregion = \"eu\"\n",
+			want: []string{"config.toml:1: [accounts.prod] has no account_id"},
+		},
+		{
+			name: "unknown account setting",
+			src:  "[accounts.prod]\naccount_id = 1\nrefresh_interval = 5\n",
+			want: []string{`config.toml:3: unknown account setting "refresh_interval"`},
+		},
+		{
+			name: "references to missing sections",
+			src:  "account = \"prod\"\nprofile = \"oncall\"\nview = \"web\"\ntheme = \"dark\"\n",
+			want: []string{
+				"config.toml:1: account: no [accounts.prod] table",
+				"config.toml:2: profile: no [profiles.oncall] table",
+				`config.toml:3: view: unknown view "web"`,
+				`config.toml:4: theme: unknown theme "dark"`,
+			},
+		},
+		{
+			name: "sections can be referenced before they are defined",
+			src:  "account = \"prod\"\nview = \"web\"\n\n[accounts.prod]\naccount_id = 1\n\n[views.web]\nshow = \"flapping\"\n",
+		},
+		{
+			name: "bad view",
+			src:  "[views.web]\nshow = \"broken\"\nsort = \"name\"\n",
+			want: []string{
+				`config.toml:2: show: unknown view "broken"`,
+				`config.toml:3: unknown view setting "sort"`,
+			},
+		},
+		{
+			name: "bad theme",
+			src:  "[themes.dark]\nok = \"greenish\"\nwarning = \"red\"\n",
+			want: []string{
+				`config.toml:2: unknown color "greenish"`,
+				`config.toml:3: unknown theme color "warning"`,
+			},
+		},
+		{
+			name: "bad keybindings",
+			src:  "[keybindings]\nexplode = \"x\"\nquit = \"ctrl-q\"\nlogs = \"s\"\n",
+			want: []string{
+				`config.toml:2: unknown action "explode"`,
+				"config.toml:3: quit: ",
+				`config.toml:4: "s" is bound to both`,
+			},
+		},
+		{
+			name: "profile errors are reported even when unused",
+			src:  "[profiles.oncall]\nprofile = \"other\"\nrefresh_interval = -1\nview = \"missing\"\n",
+			want: []string{
+				"config.toml:2: profiles cannot select another profile",
+				"config.toml:3: refresh_interval: want a whole number",
+				`config.toml:4: view: unknown view "missing"`,
+			},
+		},
+		{
+			name: "syntax errors are reported alongside schema errors",
+			src:  "filter = web\nrefresh = 1\n",
+			want: []string{
+				`config.toml:1: invalid value "web"`,
+				`config.toml:2: unknown setting "refresh"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := loadTOMLConfig(defaultConfig(), "config.toml", tt.src)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("got %d errors %v, want %d", len(errs), errs, len(tt.want))
+			}
+			for i, err := range errs {
+				if !strings.HasPrefix(err.Error(), tt.want[i]) {
+					t.Errorf("error %d = %q, want prefix %q", i, err, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestKeyValueConfigKeepsDefaultsOnBadValues(t *testing.T) {
+	cfg := defaultConfig()
+	errs := loadKeyValueConfig(cfg, "config", "# comment\nrefresh_interval=abc\nhistory_days=14\nnonsense\ncolour=red\nadaptive_refresh=maybe\n")
+
+	want := []string{
+		"config:2: refresh_interval: want a whole number of at least 1",
+		"config:4: expected key = value",
+		`config:5: unknown setting "colour"`,
+		"config:6: adaptive_refresh: want true or false",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors %v, want %d", len(errs), errs, len(want))
+	}
+	for i, err := range errs {
+		if !strings.HasPrefix(err.Error(), want[i]) {
+			t.Errorf("error %d = %q, want prefix %q", i, err, want[i])
+		}
+	}
+	if cfg.RefreshInterval != 30 || !cfg.AdaptiveRefresh || cfg.HistoryDays != 14 {
+		t.Errorf("refresh_interval = %d, adaptive_refresh = %v, history_days = %d; want 30, true, 14",
+			cfg.RefreshInterval, cfg.AdaptiveRefresh, cfg.HistoryDays)
+	}
+}
+
+func TestOptionsApplyPrecedence(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		accountID string
+		region    string
+		apiKey    string
+		apiKeyEnv string
+		refresh   int
+		view      string
+		filter    string
+		err       string
+	}{
+		{
+			name:      "config defaults",
+			accountID: "1234567", region: "us", apiKey: "NRAK-TOP", refresh: 60, view: "alerting", filter: "web-",
+		},
+		{
+			name:      "profile switches account, which brings its own key",
+			args:      []string{"--profile", "oncall"},
+			accountID: "7654321", region: "eu", apiKeyEnv: "NR_EU_KEY", refresh: 15, view: "alerting", filter: "web-",
+		},
+		{
+			name:      "flags override the profile",
+			args:      []string{"--profile", "oncall", "--account", "prod", "--refresh", "5", "--view", "all", "--filter", "db"},
+			accountID: "1234567", region: "us", apiKey: "NRAK-TOP", refresh: 5, view: "all", filter: "db",
+		},
+		{
+			name:      "account by ID",
+			args:      []string{"--account", "42,43"},
+			accountID: "42,43", region: "us", apiKey: "NRAK-TOP", refresh: 60, view: "alerting", filter: "web-",
+		},
+		{
+			name: "unknown account name",
+			args: []string{"--account", "staging"},
+			err:  `--account: invalid account ID "staging"`,
+		},
+		{
+			name: "unknown profile",
+			args: []string{"--profile", "weekend"},
+			err:  `unknown profile "weekend"`,
+		},
+		{
+			name: "unknown view",
+			args: []string{"--view", "busy"},
+			err:  `unknown view "busy"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			if errs := loadTOMLConfig(cfg, "config.toml", testTOMLConfig); len(errs) > 0 {
+				t.Fatalf("unexpected config errors: %v", errs)
+			}
+			opts, _, err := parseFlags(tt.args, io.Discard)
+			if err != nil {
+				t.Fatalf("parseFlags: %v", err)
+			}
+			err = opts.Apply(cfg)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Apply error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if cfg.AccountID != tt.accountID || cfg.Region != tt.region || cfg.APIKey != tt.apiKey || cfg.APIKeyEnv != tt.apiKeyEnv {
+				t.Errorf("account %q region %q api_key %q api_key_env %q, want %q %q %q %q",
+					cfg.AccountID, cfg.Region, cfg.APIKey, cfg.APIKeyEnv, tt.accountID, tt.region, tt.apiKey, tt.apiKeyEnv)
+			}
+			if cfg.RefreshInterval != tt.refresh || cfg.View != tt.view || cfg.Filter != tt.filter {
+				t.Errorf("refresh %d view %q filter %q, want %d %q %q",
+					cfg.RefreshInterval, cfg.View, cfg.Filter, tt.refresh, tt.view, tt.filter)
+			}
+		})
+	}
+}
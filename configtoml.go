@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// loadTOMLConfig reads config.toml into cfg. Top-level keys are the same settings as the
+// key=value format; named sections live in these tables:
+//
+//	[accounts.NAME]  account_id and API key settings, picked with account = or --account
+//	[profiles.NAME]  any top-level settings, picked with profile = or --profile
+//	[views.NAME]     filter and show (all, alerting or flapping), picked with view = or --view
+//	[themes.NAME]    ok, alert, flapping and not_reporting colors, picked with theme =
+//	[keybindings]    action = "key" for the main view
+//
+// Every problem is returned with its line number; none of them are skipped silently.
+func loadTOMLConfig(cfg *Config, path, src string) []error {
+	doc, errs := parseTOML(path, src)
+	fail := func(line int, format string, args ...any) {
+		errs = append(errs, &ConfigError{Path: path, Line: line, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	tableLines := make(map[string]int)
+	for _, t := range doc.tables {
+		name := strings.Join(t.name, ".")
+		tableLines[name] = t.line
+		switch {
+		case len(t.name) == 1 && t.name[0] == "keybindings":
+		case len(t.name) == 1 && isSectionTable(t.name[0]):
+			// [accounts] on its own is allowed as a heading for the [accounts.NAME] tables
+		case len(t.name) == 2 && isSectionTable(t.name[0]):
+			switch t.name[0] {
+			case "accounts":
+				cfg.Accounts[t.name[1]] = nil
+			case "profiles":
+				cfg.Profiles[t.name[1]] = nil
+			case "views":
+				cfg.Views[t.name[1]] = NamedView{Show: "all"}
+			case "themes":
+				cfg.Themes[t.name[1]] = builtinThemes["default"]
+			}
+		default:
+			fail(t.line, "unknown table [%s] (want accounts.NAME, profiles.NAME, views.NAME, themes.NAME or keybindings)", name)
+		}
+	}
+
+	// Named sections first, so settings can refer to them wherever they appear in the file
+	var root, profiles []tomlEntry
+	for _, e := range doc.entries {
+		switch {
+		case len(e.table) == 0:
+			root = append(root, e)
+		case len(e.table) == 1 && e.table[0] == "keybindings":
+			loadKeybinding(cfg, e, fail)
+		case len(e.table) != 2:
+			if len(e.table) == 1 && isSectionTable(e.table[0]) {
+				fail(e.line, "%q must be inside a [%s.NAME] table", e.key, e.table[0])
+			}
+		case e.table[0] == "accounts":
+			if !contains(accountSettings, e.key) {
+				fail(e.line, "unknown account setting %q (want %s)", e.key, strings.Join(accountSettings, ", "))
+				continue
+			}
+			if s, err := tomlSetting(e); err != nil {
+				fail(e.line, "%v", err)
+			} else if err := s.applyTo(defaultConfig()); err != nil {
+				fail(e.line, "%s: %v", e.key, err)
+			} else {
+				cfg.Accounts[e.table[1]] = append(cfg.Accounts[e.table[1]], s)
+			}
+		case e.table[0] == "views":
+			loadView(cfg, e, fail)
+		case e.table[0] == "themes":
+			t := cfg.Themes[e.table[1]]
+			if e.value.kind != tomlString {
+				fail(e.line, "%s must be a string, not %s", e.key, e.value.kind)
+			} else if err := t.setThemeColor(e.key, e.value.str); err != nil {
+				fail(e.line, "%v", err)
+			}
+			cfg.Themes[e.table[1]] = t
+		case e.table[0] == "profiles":
+			profiles = append(profiles, e)
+		}
+	}
+	for name, account := range cfg.Accounts {
+		if !hasSetting(account, "account_id") {
+			fail(tableLines["accounts."+name], "[accounts.%s] has no account_id", name)
+		}
+	}
+	checkKeybindings(cfg, doc, fail)
+
+	// Profiles are checked against a scratch config so a bad one is caught even when unused
+	for _, e := range profiles {
+		if e.key == "profile" {
+			fail(e.line, "profiles cannot select another profile")
+			continue
+		}
+		s, err := tomlSetting(e)
+		if err != nil {
+			fail(e.line, "%v", err)
+			continue
+		}
+		scratch := defaultConfig()
+		scratch.Accounts, scratch.Views, scratch.Themes = cfg.Accounts, cfg.Views, cfg.Themes
+		if err := s.applyTo(scratch); err != nil {
+			fail(e.line, "%s: %v", e.key, err)
+			continue
+		}
+		cfg.Profiles[e.table[1]] = append(cfg.Profiles[e.table[1]], s)
+	}
+
+	for _, e := range root {
+		s, err := tomlSetting(e)
+		if err != nil {
+			fail(e.line, "%v", err)
+			continue
+		}
+		if err := s.applyTo(cfg); err != nil {
+			fail(e.line, "%s: %v", e.key, err)
+		}
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		a, _ := errs[i].(*ConfigError)
+		b, _ := errs[j].(*ConfigError)
+		return a != nil && b != nil && a.Line < b.Line
+	})
+	return errs
+}
+
+func isSectionTable(name string) bool {
+	return name == "accounts" || name == "profiles" || name == "views" || name == "themes"
+}
+
+// tomlSetting checks a value's type against settingKinds and converts it to the string form
+// applySetting takes
+func tomlSetting(e tomlEntry) (setting, error) {
+	kind, ok := settingKinds[e.key]
+	if !ok {
+		return setting{}, fmt.Errorf("unknown setting %q", e.key)
+	}
+	s := setting{key: e.key, line: e.line}
+	v := e.value
+	switch {
+	case kind == kindString && v.kind == tomlString,
+		kind == kindID && v.kind == tomlString,
+		(kind == kindList || kind == kindRepeated) && v.kind == tomlString:
+		s.values = []string{v.str}
+	case (kind == kindInt || kind == kindID) && v.kind == tomlInt:
+		s.values = []string{strconv.FormatInt(v.num, 10)}
+	case kind == kindBool && v.kind == tomlBool:
+		s.values = []string{strconv.FormatBool(v.b)}
+	case (kind == kindList || kind == kindRepeated) && v.kind == tomlArray:
+		for _, item := range v.arr {
+			if item.kind != tomlString {
+				return setting{}, fmt.Errorf("%s must be %s, not an array containing %s", e.key, kind, item.kind)
+			}
+			s.values = append(s.values, item.str)
+		}
+		if kind == kindList {
+			s.values = []string{strings.Join(s.values, ",")}
+		}
+	default:
+		return setting{}, fmt.Errorf("%s must be %s, not %s", e.key, kind, v.kind)
+	}
+	return s, nil
+}
+
+func loadView(cfg *Config, e tomlEntry, fail func(int, string, ...any)) {
+	view := cfg.Views[e.table[1]]
+	if e.value.kind != tomlString {
+		fail(e.line, "%s must be a string, not %s", e.key, e.value.kind)
+		return
+	}
+	switch e.key {
+	case "filter":
+		view.Filter = e.value.str
+	case "show":
+		if !contains(validViews, e.value.str) {
+			fail(e.line, "show: unknown view %q (want %s)", e.value.str, strings.Join(validViews, ", "))
+			return
+		}
+		view.Show = e.value.str
+	default:
+		fail(e.line, "unknown view setting %q (want filter or show)", e.key)
+		return
+	}
+	cfg.Views[e.table[1]] = view
+}
+
+func loadKeybinding(cfg *Config, e tomlEntry, fail func(int, string, ...any)) {
+	if _, ok := cfg.Keys[e.key]; !ok {
+		fail(e.line, "unknown action %q (want %s)", e.key, strings.Join(keyActions, ", "))
+		return
+	}
+	if e.value.kind != tomlString {
+		fail(e.line, "%s must be a string, not %s", e.key, e.value.kind)
+		return
+	}
+	r, err := parseKey(e.value.str)
+	if err != nil {
+		fail(e.line, "%s: %v", e.key, err)
+		return
+	}
+	cfg.Keys[e.key] = r
+}
+
+// checkKeybindings reports keys bound to two actions, including clashes with a default binding
+// that wasn't changed
+func checkKeybindings(cfg *Config, doc *tomlDoc, fail func(int, string, ...any)) {
+	lines := make(map[string]int)
+	for _, e := range doc.entries {
+		if len(e.table) == 1 && e.table[0] == "keybindings" {
+			lines[e.key] = e.line
+		}
+	}
+	byKey := make(map[rune]string)
+	for _, action := range keyActions {
+		r := cfg.Keys[action]
+		other, taken := byKey[r]
+		if !taken {
+			byKey[r] = action
+			continue
+		}
+		line := lines[action]
+		if lines[other] > line {
+			line = lines[other]
+		}
+		fail(line, "%q is bound to both %s and %s", keyLabel(r), other, action)
+	}
+}
+
+func hasSetting(settings []setting, key string) bool {
+	for _, s := range settings {
+		if s.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTo applies every value of s to cfg, returning the first error without a location
+func (s setting) applyTo(cfg *Config) error {
+	for _, value := range s.values {
+		if err := applySetting(cfg, s.key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
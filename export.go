@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// exportExtensions maps export formats to file extensions
+var exportExtensions = map[string]string{
+	"csv":      "csv",
+	"json":     "json",
+	"markdown": "md",
+}
+
+// exportEntities writes entities to path, or to a timestamped file in dir when path is empty.
+// It returns the written path and contents.
+func exportEntities(entities []*Entity, format, dir, path string) (string, []byte, error) {
+	ext, ok := exportExtensions[format]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown export format %q (want csv, json or markdown)", format)
+	}
+
+	var buf bytes.Buffer
+	if err := writeEntities(&buf, format, entities); err != nil {
+		return "", nil, err
+	}
+	// Exports end up in tickets and chat, so they get the same redaction as logs
+	redactor.AddHostNames(entities)
+	data := []byte(redactor.Redact(buf.String()))
+
+	if path == "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", nil, err
+		}
+		path = filepath.Join(dir, fmt.Sprintf("osiris-%s.%s", time.Now().Format("20060102-150405"), ext))
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", nil, err
+	}
+	logFor("export").Info("exported entities", "entities", len(entities), "path", path)
+	return path, data, nil
+}
+
+// copyToClipboard pipes data into the first clipboard tool found for this platform
+func copyToClipboard(data []byte) error {
+	candidates := [][]string{
+		{"wl-copy"},
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+		{"clip.exe"},
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case "windows":
+		candidates = [][]string{{"clip"}}
+	}
+
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(c[0], c[1:]...)
+		cmd.Stdin = bytes.NewReader(data)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %v: %s", c[0], err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	return fmt.Errorf("no clipboard tool found")
+}
+
+func cmdExport(config *Config, args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("f", config.ExportFormat, "export format: csv, json or markdown")
+	path := fs.String("path", "", "file to write (default a timestamped file in "+config.ExportDir+")")
+	clipboard := fs.Bool("clipboard", config.ExportClipboard, "also copy the export to the clipboard")
+	match := fs.String("name", "", "only entities whose name contains this text")
+	alerting := fs.Bool("alerting", false, "only alerting entities")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	entities, incidentsOK, err := fetchSnapshot(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "osiris: "+err.Error())
+		return exitError
+	}
+	filtered := make([]*Entity, 0, len(entities))
+	for _, e := range entities {
+		if *match != "" && !strings.Contains(strings.ToLower(e.Name), strings.ToLower(*match)) {
+			continue
+		}
+		if *alerting && !e.HasAlert {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+
+	written, data, err := exportEntities(filtered, *format, config.ExportDir, *path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "osiris: "+err.Error())
+		return exitError
+	}
+	fmt.Println(written)
+	if *clipboard {
+		if err := copyToClipboard(data); err != nil {
+			fmt.Fprintln(os.Stderr, "osiris: clipboard: "+err.Error())
+		}
+	}
+	return resultCode(filtered, incidentsOK)
+}
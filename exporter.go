@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fetchBuckets are the upper bounds, in seconds, of the fetch latency histogram
+var fetchBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 15, 30}
+
+type histogram struct {
+	counts []uint64 // per bucket, non-cumulative
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, le := range fetchBuckets {
+		if v <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Exporter accumulates refresh-loop statistics and renders them in the Prometheus text format
+type Exporter struct {
+	mu          sync.Mutex
+	accounts    []string          // configured accounts, always rendered even with no entities
+	entities    map[string]int    // by account
+	alerting    map[[2]string]int // by account and severity
+	flapping    map[string]int    // by account
+	lastSuccess time.Time
+	durations   map[string]*histogram // by stage
+	errors      map[[2]string]uint64  // by stage and error type
+}
+
+// NewExporter creates an exporter whose entity gauges are labelled with each entity's account
+func NewExporter(accounts []string) *Exporter {
+	return &Exporter{
+		accounts:  accounts,
+		entities:  make(map[string]int),
+		alerting:  make(map[[2]string]int),
+		flapping:  make(map[string]int),
+		durations: make(map[string]*histogram),
+		errors:    make(map[[2]string]uint64),
+	}
+}
+
+// ObserveFetch records how long a refresh stage took and, when errType is set, that it failed
+func (e *Exporter) ObserveFetch(stage string, d time.Duration, errType string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	h, ok := e.durations[stage]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(fetchBuckets))}
+		e.durations[stage] = h
+	}
+	h.observe(d.Seconds())
+	if errType != "" {
+		e.errors[[2]string{stage, errType}]++
+	}
+}
+
+// SetSnapshot updates the entity gauges from a completed refresh
+func (e *Exporter) SetSnapshot(entities []*Entity, success bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.entities = make(map[string]int)
+	e.alerting = make(map[[2]string]int)
+	e.flapping = make(map[string]int)
+	for _, ent := range entities {
+		e.entities[ent.AccountID]++
+		if ent.HasAlert {
+			e.alerting[[2]string{ent.AccountID, severityLabel(ent)}]++
+		}
+		if ent.Flapping {
+			e.flapping[ent.AccountID]++
+		}
+	}
+	if success {
+		e.lastSuccess = time.Now()
+	}
+}
+
+// classifyFetchError buckets FetchEntities error messages into a small set of label values
+func classifyFetchError(msg string) string {
+	switch {
+	case msg == "":
+		return ""
+	case strings.Contains(msg, "not configured"):
+		return "config"
+	case strings.Contains(msg, "Timeout") || strings.Contains(msg, "deadline exceeded"):
+		return "timeout"
+	case strings.HasPrefix(msg, "Error fetching") || strings.HasPrefix(msg, "Error reading"):
+		return "network"
+	case strings.HasPrefix(msg, "Error parsing"):
+		return "parse"
+	case strings.HasPrefix(msg, "New Relic API error"):
+		return "api"
+	}
+	return "other"
+}
+
+// Render writes all metrics in the Prometheus text exposition format
+func (e *Exporter) Render(w io.Writer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Every configured account gets a series, plus any account entities came back with
+	seen := make(map[string]bool)
+	accounts := make([]string, 0, len(e.accounts))
+	for _, account := range e.accounts {
+		if !seen[account] {
+			seen[account] = true
+			accounts = append(accounts, account)
+		}
+	}
+	for account := range e.entities {
+		if !seen[account] {
+			seen[account] = true
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Strings(accounts)
+
+	fmt.Fprintln(w, "# HELP osiris_entities_total Entities returned by the last refresh.")
+	fmt.Fprintln(w, "# TYPE osiris_entities_total gauge")
+	for _, account := range accounts {
+		fmt.Fprintf(w, "osiris_entities_total{account=\"%s\"} %d\n", escapeLabel(account), e.entities[account])
+	}
+
+	fmt.Fprintln(w, "# HELP osiris_alerting_entities Entities with an open alert, by severity.")
+	fmt.Fprintln(w, "# TYPE osiris_alerting_entities gauge")
+	for _, account := range accounts {
+		for _, sev := range []string{"critical", "warning", "unknown"} {
+			fmt.Fprintf(w, "osiris_alerting_entities{account=\"%s\",severity=\"%s\"} %d\n",
+				escapeLabel(account), sev, e.alerting[[2]string{account, sev}])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP osiris_flapping_entities Entities currently marked as flapping.")
+	fmt.Fprintln(w, "# TYPE osiris_flapping_entities gauge")
+	for _, account := range accounts {
+		fmt.Fprintf(w, "osiris_flapping_entities{account=\"%s\"} %d\n", escapeLabel(account), e.flapping[account])
+	}
+
+	fmt.Fprintln(w, "# HELP osiris_fetch_duration_seconds Time spent in each refresh stage.")
+	fmt.Fprintln(w, "# TYPE osiris_fetch_duration_seconds histogram")
+	stages := make([]string, 0, len(e.durations))
+	for stage := range e.durations {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+	for _, stage := range stages {
+		h := e.durations[stage]
+		var cumulative uint64
+		for i, le := range fetchBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "osiris_fetch_duration_seconds_bucket{stage=\"%s\",le=\"%g\"} %d\n", stage, le, cumulative)
+		}
+		fmt.Fprintf(w, "osiris_fetch_duration_seconds_bucket{stage=\"%s\",le=\"+Inf\"} %d\n", stage, h.count)
+		fmt.Fprintf(w, "osiris_fetch_duration_seconds_sum{stage=\"%s\"} %g\n", stage, h.sum)
+		fmt.Fprintf(w, "osiris_fetch_duration_seconds_count{stage=\"%s\"} %d\n", stage, h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP osiris_fetch_errors_total Failed refresh stages, by error type.")
+	fmt.Fprintln(w, "# TYPE osiris_fetch_errors_total counter")
+	keys := make([][2]string, 0, len(e.errors))
+	for k := range e.errors {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "osiris_fetch_errors_total{stage=\"%s\",type=\"%s\"} %d\n", k[0], k[1], e.errors[k])
+	}
+
+	fmt.Fprintln(w, "# HELP osiris_last_successful_refresh_timestamp_seconds Unix time of the last fully successful refresh.")
+	fmt.Fprintln(w, "# TYPE osiris_last_successful_refresh_timestamp_seconds gauge")
+	last := 0.0
+	if !e.lastSuccess.IsZero() {
+		last = float64(e.lastSuccess.UnixNano()) / 1e9
+	}
+	fmt.Fprintf(w, "osiris_last_successful_refresh_timestamp_seconds %g\n", last)
+}
+
+// ServeHTTP serves /metrics
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.Render(w)
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
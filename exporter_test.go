@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExporterLabelsEntityAccounts(t *testing.T) {
+	e := NewExporter([]string{"1", "2", "3"})
+	e.SetSnapshot([]*Entity{
+		{AccountID: "1", HasAlert: true, Severity: "critical"},
+		{AccountID: "1"},
+		{AccountID: "2", HasAlert: true, Severity: "warning", Flapping: true},
+	}, true)
+	var out strings.Builder
+	e.Render(&out)
+
+	for _, want := range []string{
+		`osiris_entities_total{account="1"} 2`,
+		`osiris_entities_total{account="2"} 1`,
+		`osiris_entities_total{account="3"} 0`,
+		`osiris_alerting_entities{account="1",severity="critical"} 1`,
+		`osiris_alerting_entities{account="1",severity="warning"} 0`,
+		`osiris_alerting_entities{account="2",severity="warning"} 1`,
+		`osiris_flapping_entities{account="2"} 1`,
+		`osiris_flapping_entities{account="3"} 0`,
+	} {
+		if !strings.Contains(out.String(), want+"\n") {
+			t.Errorf("missing %s in:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// validViews are the entity subsets the list can start in
+var validViews = []string{"all", "alerting", "flapping"}
+
+// validLogLevels are accepted by --log-level and log_level=
+var validLogLevels = []string{"debug", "info", "warn", "error", "off"}
+
+// Options holds global command-line flags; fields only override config when the flag was given
+type Options struct {
+	ConfigPath string
+	Profile    string
+	Account    string
+	Refresh    int
+	Filter     string
+	View       string
+	Demo       bool
+	LogLevel   string
+	LogFile    string
+	Listen     string
+	Headless   bool
+	Version    bool
+
+	set map[string]bool
+}
+
+// parseFlags parses global flags and returns the remaining arguments (an optional subcommand).
+// flag.ErrHelp is returned after usage has been printed for --help.
+func parseFlags(args []string, stderr io.Writer) (*Options, []string, error) {
+	opts := &Options{set: make(map[string]bool)}
+
+	fs := flag.NewFlagSet("osiris", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.ConfigPath, "config", "", "path to the config file (default ~/.osiris/config.toml, else ~/.osiris/config)")
+	fs.StringVar(&opts.Profile, "profile", "", "apply a [profiles] entry from config.toml")
+	fs.StringVar(&opts.Account, "account", "", "New Relic account ID or [accounts] name, overrides account_id")
+	fs.IntVar(&opts.Refresh, "refresh", 0, "refresh interval in seconds, overrides refresh_interval")
+	fs.StringVar(&opts.Filter, "filter", "", "only show entities whose name contains this text")
+	fs.StringVar(&opts.View, "view", "", "entities to show: "+strings.Join(validViews, ", ")+", or a [views] name")
+	fs.BoolVar(&opts.Demo, "demo", false, "use built-in demo entities instead of calling New Relic")
+	fs.StringVar(&opts.LogLevel, "log-level", "", "log level: "+strings.Join(validLogLevels, ", "))
+	fs.StringVar(&opts.LogFile, "log-file", "", "write the log here instead of ~/.osiris/debug.log (log_file)")
+	fs.StringVar(&opts.Listen, "listen", "", "serve /metrics and the JSON API on this address, e.g. 127.0.0.1:9273 (http_listen)")
+	debug := fs.Bool("debug", false, "shorthand for --log-level debug")
+	fs.BoolVar(&opts.Headless, "headless", false, "run the refresh loop without a TUI, logging events as JSON lines")
+	fs.BoolVar(&opts.Version, "version", false, "print the version and exit")
+
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: osiris [flags] [command]\n\n")
+		fmt.Fprintf(stderr, "Commands:\n")
+		fmt.Fprintf(stderr, "  list     list entities (-o table|json|csv|markdown, -name, -alerting, -severity, -type)\n")
+		fmt.Fprintf(stderr, "  alerts   list alerting entities\n")
+		fmt.Fprintf(stderr, "  show     show one entity by name\n")
+		fmt.Fprintf(stderr, "  ssh      ssh to an entity by name\n")
+		fmt.Fprintf(stderr, "  export   write entities to ~/.osiris/exports (-f csv|json|markdown, -path, -clipboard)\n")
+		fmt.Fprintf(stderr, "  config   config check [path]: validate the config file and report errors by line\n\n")
+		fmt.Fprintf(stderr, "Without a command the interactive console starts.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		opts.set[f.Name] = true
+	})
+
+	if *debug && !opts.set["log-level"] {
+		opts.LogLevel = "debug"
+		opts.set["log-level"] = true
+	}
+	if opts.set["log-level"] && !contains(validLogLevels, opts.LogLevel) {
+		return nil, nil, fmt.Errorf("invalid --log-level %q (want %s)", opts.LogLevel, strings.Join(validLogLevels, ", "))
+	}
+	if opts.set["refresh"] && opts.Refresh <= 0 {
+		return nil, nil, fmt.Errorf("--refresh must be a positive number of seconds")
+	}
+	return opts, fs.Args(), nil
+}
+
+// Apply selects the profile, account and view, then overrides config values with the flags
+// that were given on the command line
+func (o *Options) Apply(cfg *Config) error {
+	if o.set["profile"] {
+		cfg.Profile = o.Profile
+	}
+	if cfg.Profile != "" {
+		if err := cfg.useProfile(cfg.Profile); err != nil {
+			return err
+		}
+	}
+	// --account takes an [accounts] name, or else a plain account ID
+	_, namedAccount := cfg.Accounts[o.Account]
+	if o.set["account"] && namedAccount {
+		cfg.Account = o.Account
+	}
+	if o.set["view"] {
+		cfg.View = o.View
+	}
+	if err := cfg.resolve(); err != nil {
+		return err
+	}
+
+	if o.set["account"] && !namedAccount {
+		if err := applySetting(cfg, "account_id", o.Account); err != nil {
+			return fmt.Errorf("--account: %v", err)
+		}
+	}
+	if o.set["refresh"] {
+		cfg.RefreshInterval = o.Refresh
+	}
+	if o.set["filter"] {
+		cfg.Filter = o.Filter
+	}
+	if o.set["demo"] {
+		cfg.Demo = o.Demo
+	}
+	if o.set["log-level"] {
+		cfg.LogLevel = o.LogLevel
+	}
+	if o.set["log-file"] {
+		cfg.LogFile = o.LogFile
+	}
+	if o.set["listen"] {
+		cfg.HTTPListen = o.Listen
+	}
+	return nil
+}
+
+func contains(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// exitOnFlagError reports a flag parsing failure and exits; --help exits successfully
+func exitOnFlagError(err error) {
+	if err == flag.ErrHelp {
+		os.Exit(exitOK)
+	}
+	fmt.Fprintln(os.Stderr, "osiris: "+err.Error())
+	os.Exit(exitError)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// AlertTransition is a change in an entity's alert state between two refreshes
+type AlertTransition struct {
+	Entity   *Entity
+	Alerting bool // true when the entity started alerting, false when it resolved
+	At       time.Time
+}
+
+// AlertTracker remembers per-entity alert state across refreshes so flapping hosts can be spotted
+type AlertTracker struct {
+	mu          sync.Mutex
+	threshold   int
+	window      time.Duration
+	lastState   map[string]bool
+	transitions map[string][]time.Time
+}
+
+// NewAlertTracker marks entities as flapping once they change state more than threshold times within window
+func NewAlertTracker(threshold int, window time.Duration) *AlertTracker {
+	return &AlertTracker{
+		threshold:   threshold,
+		window:      window,
+		lastState:   make(map[string]bool),
+		transitions: make(map[string][]time.Time),
+	}
+}
+
+// Window returns the period over which transitions are counted
+func (t *AlertTracker) Window() time.Duration {
+	return t.window
+}
+
+// entityKey identifies an entity across refreshes; demo entities have no GUID so fall back to the name
+func entityKey(e *Entity) string {
+	if e.GUID != "" {
+		return e.GUID
+	}
+	return "name:" + e.Name
+}
+
+// Observe records the alert state of a completed refresh, sets Flapping/FlapCount on each
+// entity and returns the transitions since the previous refresh. Entities seen for the
+// first time establish a baseline and never produce a transition. entities must be the full
+// result of a successful refresh, since entities missing from it are forgotten.
+func (t *AlertTracker) Observe(entities []*Entity) []AlertTransition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-t.window)
+	changes := make([]AlertTransition, 0)
+
+	present := make(map[string]bool, len(entities))
+	for _, e := range entities {
+		key := entityKey(e)
+		present[key] = true
+		prev, seen := t.lastState[key]
+		t.lastState[key] = e.HasAlert
+		if seen && prev != e.HasAlert {
+			t.transitions[key] = append(t.transitions[key], now)
+			changes = append(changes, AlertTransition{Entity: e, Alerting: e.HasAlert, At: now})
+			logFor("alerts").Info("alert state changed", "entity", e.Name, "alerting", e.HasAlert)
+		}
+
+		// Drop transitions that have aged out of the window
+		recent := t.transitions[key][:0]
+		for _, ts := range t.transitions[key] {
+			if ts.After(cutoff) {
+				recent = append(recent, ts)
+			}
+		}
+		if len(recent) == 0 {
+			delete(t.transitions, key)
+		} else {
+			t.transitions[key] = recent
+		}
+
+		e.FlapCount = len(recent)
+		e.Flapping = e.FlapCount > t.threshold
+	}
+
+	// Forget hosts that are gone so the maps don't grow with every host ever seen
+	for key := range t.lastState {
+		if !present[key] {
+			delete(t.lastState, key)
+			delete(t.transitions, key)
+		}
+	}
+	return changes
+}
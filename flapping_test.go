@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlertTrackerForgetsRemovedEntities(t *testing.T) {
+	tr := NewAlertTracker(1, time.Hour)
+	web := &Entity{GUID: "web", HasAlert: false}
+	db := &Entity{GUID: "db", HasAlert: false}
+	tr.Observe([]*Entity{web, db})
+	tr.Observe([]*Entity{{GUID: "web", HasAlert: true}, {GUID: "db", HasAlert: true}})
+
+	tr.Observe([]*Entity{{GUID: "web", HasAlert: true}})
+	if _, ok := tr.lastState["db"]; ok || len(tr.transitions["db"]) > 0 {
+		t.Errorf("state for a removed entity was kept: %v %v", tr.lastState, tr.transitions)
+	}
+	if len(tr.transitions["web"]) != 1 {
+		t.Errorf("web transitions = %v, want the one still in the window", tr.transitions["web"])
+	}
+
+	// A host that comes back starts from a new baseline
+	if changes := tr.Observe([]*Entity{{GUID: "web", HasAlert: true}, {GUID: "db", HasAlert: false}}); len(changes) != 0 {
+		t.Errorf("changes = %v, want none for a returning host", changes)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runHeadless runs the refresh loop without a terminal UI, writing one JSON event per line to
+// stdout, until SIGINT or SIGTERM
+func runHeadless(parent context.Context, state *AppState, config *Config) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	state.eventLog = redactWriter{os.Stdout}
+	startHTTPServer(state, config)
+
+	writeEvent(state.eventLog, "info", "start", map[string]interface{}{
+		"refresh_interval": config.RefreshInterval,
+		"account_id":       config.AccountID,
+	})
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go refreshEntities(ctx, state, config, true, func() {})
+	go state.scheduler.Run(ctx, func() {
+		refreshEntities(ctx, state, config, false, func() {})
+	})
+
+	sig := <-sigs
+	writeEvent(state.eventLog, "info", "stop", map[string]interface{}{"signal": sig.String()})
+}
+
+// writeEvent writes a single structured JSON log line
+func writeEvent(w io.Writer, level, event string, fields map[string]interface{}) {
+	line := map[string]interface{}{
+		"ts":    time.Now().UTC().Format(time.RFC3339),
+		"level": level,
+		"event": event,
+	}
+	for k, v := range fields {
+		line[k] = v
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		logFor("headless").Error("encoding event failed", "err", err)
+		return
+	}
+	fmt.Fprintln(w, string(b))
+}
+
+// logRefresh writes one line per alert transition followed by a summary of the refresh
+func logRefresh(w io.Writer, entities []*Entity, incidentsOK bool, changes []AlertTransition) {
+	for _, t := range changes {
+		fields := map[string]interface{}{
+			"entity": t.Entity.Name,
+			"guid":   t.Entity.GUID,
+		}
+		if t.Alerting {
+			fields["severity"] = severityLabel(t.Entity)
+			fields["condition"] = t.Entity.AlertType
+			fields["message"] = t.Entity.AlertMessage
+			writeEvent(w, "warn", "alerting", fields)
+		} else {
+			writeEvent(w, "info", "resolved", fields)
+		}
+	}
+
+	alerting, flapping := 0, 0
+	for _, e := range entities {
+		if e.HasAlert {
+			alerting++
+		}
+		if e.Flapping {
+			flapping++
+		}
+	}
+	level := "info"
+	if !incidentsOK {
+		level = "error"
+	}
+	writeEvent(w, level, "refresh", map[string]interface{}{
+		"entities":     len(entities),
+		"alerting":     alerting,
+		"flapping":     flapping,
+		"transitions":  len(changes),
+		"incidents_ok": incidentsOK,
+	})
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// recurringThreshold is how many incidents for one condition in the window count as recurring
+const recurringThreshold = 3
+
+// Incident is one open/close cycle reconstructed from NrAiIncident events
+type Incident struct {
+	ID        string
+	Condition string
+	Policy    string
+	Priority  string
+	Title     string
+	Opened    time.Time
+	Closed    time.Time // zero while the incident is still open
+}
+
+// Duration returns how long the incident lasted, or has lasted so far if still open
+func (i *Incident) Duration() time.Duration {
+	if i.Closed.IsZero() {
+		return time.Since(i.Opened)
+	}
+	return i.Closed.Sub(i.Opened)
+}
+
+// FetchIncidentHistory returns incidents for an entity over the last N days, newest first
+func FetchIncidentHistory(ctx context.Context, config *Config, entity *Entity, days int) ([]*Incident, error) {
+	where := fmt.Sprintf("`entity.name` = '%s'", nrqlQuote(entity.Name))
+	if entity.GUID != "" {
+		where = fmt.Sprintf("(`entity.guid` = '%s' OR %s)", nrqlQuote(entity.GUID), where)
+	}
+	query := fmt.Sprintf("SELECT timestamp, incidentId, event, conditionName, policyName, priority, title, openTime, closeTime "+
+		"FROM NrAiIncident WHERE %s SINCE %d days ago LIMIT MAX", where, days)
+
+	rows, err := runNRQL(ctx, config, entity.AccountID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*Incident)
+	for _, row := range rows {
+		id := formatNRQLValue("incidentId", row["incidentId"])
+		inc, ok := byID[id]
+		if !ok {
+			inc = &Incident{ID: id}
+			byID[id] = inc
+		}
+		if v, ok := row["conditionName"].(string); ok {
+			inc.Condition = v
+		}
+		if v, ok := row["policyName"].(string); ok {
+			inc.Policy = v
+		}
+		if v, ok := row["priority"].(string); ok {
+			inc.Priority = v
+		}
+		if v, ok := row["title"].(string); ok {
+			inc.Title = v
+		}
+		if v, ok := row["openTime"].(float64); ok && v > 0 {
+			inc.Opened = time.UnixMilli(int64(v))
+		}
+		event, _ := row["event"].(string)
+		ts, _ := row["timestamp"].(float64)
+		switch event {
+		case "open":
+			if inc.Opened.IsZero() {
+				inc.Opened = time.UnixMilli(int64(ts))
+			}
+		case "close":
+			if v, ok := row["closeTime"].(float64); ok && v > 0 {
+				inc.Closed = time.UnixMilli(int64(v))
+			} else {
+				inc.Closed = time.UnixMilli(int64(ts))
+			}
+		}
+	}
+
+	incidents := make([]*Incident, 0, len(byID))
+	for _, inc := range byID {
+		if inc.Opened.IsZero() {
+			// Opened before the window; only the close event is visible
+			continue
+		}
+		incidents = append(incidents, inc)
+	}
+	sort.Slice(incidents, func(i, j int) bool {
+		return incidents[i].Opened.After(incidents[j].Opened)
+	})
+	logFor("history").Debug("fetched incident history", "entity", entity.Name, "incidents", len(incidents))
+	return incidents, nil
+}
+
+// formatDuration renders a duration compactly, e.g. "3d4h", "2h15m", "45s"
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm%ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%ds", int(d.Seconds()))
+}
+
+// HistoryPanel shows the incident timeline for the selected host
+type HistoryPanel struct {
+	root    *tview.Flex
+	summary *tview.TextView
+	table   *tview.Table
+
+	ctx     context.Context // the app's context, cancelled on quit
+	config  *Config
+	app     *tview.Application
+	onClose func()
+
+	entity *Entity
+	days   int
+	cancel context.CancelFunc // cancels the running load
+}
+
+// NewHistoryPanel builds the timeline; onClose is called on the UI thread when the user leaves it
+func NewHistoryPanel(ctx context.Context, config *Config, app *tview.Application, onClose func()) *HistoryPanel {
+	p := &HistoryPanel{
+		ctx:     ctx,
+		config:  config,
+		app:     app,
+		onClose: onClose,
+		days:    config.HistoryDays,
+	}
+
+	p.summary = tview.NewTextView().SetDynamicColors(true)
+	p.table = tview.NewTable().SetFixed(1, 0).SetSelectable(true, false)
+	p.table.SetBorder(true)
+
+	help := tview.NewTextView().SetDynamicColors(true).
+		SetText("[dim]↑↓[-] scroll | [dim]+/-[-] days | [dim]Esc/q[-] close")
+
+	p.root = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(p.summary, 3, 0, false).
+		AddItem(p.table, 0, 1, true).
+		AddItem(help, 1, 0, false)
+
+	p.table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEsc:
+			p.Close()
+			return nil
+		case tcell.KeyRune:
+			switch event.Rune() {
+			case 'q', 'Q':
+				p.Close()
+				return nil
+			case '+', '=':
+				p.days++
+				p.load()
+				return nil
+			case '-':
+				if p.days > 1 {
+					p.days--
+					p.load()
+				}
+				return nil
+			}
+		}
+		return event
+	})
+
+	return p
+}
+
+// Open loads the timeline for the given entity
+func (p *HistoryPanel) Open(entity *Entity) {
+	p.entity = entity
+	p.app.SetFocus(p.table)
+	p.load()
+}
+
+// Close cancels a running load and returns to the main view
+func (p *HistoryPanel) Close() {
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+	p.onClose()
+}
+
+func (p *HistoryPanel) load() {
+	entity, days := p.entity, p.days
+	p.table.Clear()
+	p.table.SetTitle(fmt.Sprintf(" Incident history: %s (last %d days) ", tview.Escape(entity.Name), days))
+	p.summary.SetText("[yellow]⟳ Loading incident history...")
+
+	if p.cancel != nil {
+		p.cancel()
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.cancel = cancel
+	go func() {
+		incidents, err := FetchIncidentHistory(ctx, p.config, entity, days)
+		p.app.QueueUpdateDraw(func() {
+			if ctx.Err() != nil {
+				// Superseded by a newer load
+				return
+			}
+			if err != nil {
+				p.summary.SetText(fmt.Sprintf("[red]✗ %s", tview.Escape(err.Error())))
+				return
+			}
+			p.render(incidents)
+		})
+	}()
+}
+
+func (p *HistoryPanel) render(incidents []*Incident) {
+	perCondition := make(map[string]int)
+	open := 0
+	var total time.Duration
+	for _, inc := range incidents {
+		perCondition[inc.Condition]++
+		if inc.Closed.IsZero() {
+			open++
+		}
+		total += inc.Duration()
+	}
+
+	if len(incidents) == 0 {
+		p.summary.SetText(fmt.Sprintf("[green]✓ No incidents in the last %d days", p.days))
+	} else {
+		recurring := make([]string, 0)
+		for cond, n := range perCondition {
+			if n >= recurringThreshold {
+				recurring = append(recurring, fmt.Sprintf("%s (%d×)", cond, n))
+			}
+		}
+		sort.Strings(recurring)
+
+		text := fmt.Sprintf("%d incidents in the last %d days, [red]%d open[-], %s total alerting time\n",
+			len(incidents), p.days, open, formatDuration(total))
+		if len(recurring) > 0 {
+			text += fmt.Sprintf("[yellow]Recurring:[-] %s", tview.Escape(strings.Join(recurring, ", ")))
+		} else if open > 0 && perCondition[incidents[0].Condition] == 1 {
+			text += "[teal]Latest incident is new for this condition[-]"
+		}
+		p.summary.SetText(text)
+	}
+
+	headers := []string{"Opened", "Closed", "Duration", "Priority", "Condition", "Title"}
+	for col, h := range headers {
+		p.table.SetCell(0, col, tview.NewTableCell(h).
+			SetTextColor(tcell.ColorYellow).
+			SetAttributes(tcell.AttrBold).
+			SetSelectable(false))
+	}
+	for r, inc := range incidents {
+		closed := "[red]open[-]"
+		if !inc.Closed.IsZero() {
+			closed = inc.Closed.Format("01-02 15:04")
+		}
+		priorityColor := "yellow"
+		if inc.Priority == "critical" {
+			priorityColor = "red"
+		}
+		cells := []string{
+			inc.Opened.Format("01-02 15:04"),
+			closed,
+			formatDuration(inc.Duration()),
+			fmt.Sprintf("[%s]%s[-]", priorityColor, tview.Escape(inc.Priority)),
+			tview.Escape(inc.Condition),
+			tview.Escape(inc.Title),
+		}
+		for col, text := range cells {
+			p.table.SetCell(r+1, col, tview.NewTableCell(text).SetMaxWidth(50))
+		}
+	}
+	p.table.ScrollToBeginning()
+}
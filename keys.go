@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/tview"
+)
+
+// keyActions are the main-view actions that can be rebound in [keybindings], in the order the
+// title bar lists them
+var keyActions = []string{"ssh", "rdp", "refresh", "logs", "history", "export", "app_log", "nrql", "search", "next_match", "quit"}
+
+// defaultKeybindings returns the standard key for each action
+func defaultKeybindings() map[string]rune {
+	return map[string]rune{
+		"ssh":        's',
+		"rdp":        'r',
+		"refresh":    ' ',
+		"logs":       'l',
+		"history":    'h',
+		"export":     'e',
+		"app_log":    'd',
+		"nrql":       ':',
+		"search":     '/',
+		"next_match": 'n',
+		"quit":       'q',
+	}
+}
+
+// keyMap looks actions up by the key pressed
+type keyMap map[rune]string
+
+// keys is the main view's bindings, set from the config at startup
+var keys = newKeyMap(defaultKeybindings())
+
+func newKeyMap(bindings map[string]rune) keyMap {
+	m := make(keyMap, len(bindings))
+	for action, r := range bindings {
+		m[r] = action
+	}
+	return m
+}
+
+// action returns the action bound to r. Letters match in either case unless the other case is
+// bound to something else, so caps lock doesn't disable the keys.
+func (m keyMap) action(r rune) string {
+	if action, ok := m[r]; ok {
+		return action
+	}
+	if action, ok := m[unicode.ToLower(r)]; ok {
+		return action
+	}
+	return m[unicode.ToUpper(r)]
+}
+
+// key returns the key bound to action
+func (m keyMap) key(action string) rune {
+	for r, a := range m {
+		if a == action {
+			return r
+		}
+	}
+	return 0
+}
+
+// parseKey reads a [keybindings] value: a single character, or "space"
+func parseKey(value string) (rune, error) {
+	if value == "space" {
+		return ' ', nil
+	}
+	r, size := utf8.DecodeRuneInString(value)
+	if size == 0 || size != len(value) || !unicode.IsPrint(r) || r == ' ' {
+		return 0, fmt.Errorf("want a single character or \"space\", got %q", value)
+	}
+	return r, nil
+}
+
+// keyLabel is how a key is shown in hints
+func keyLabel(r rune) string {
+	if r == ' ' {
+		return "space"
+	}
+	return string(r)
+}
+
+// keyHint renders an action for the title bar, e.g. "[s] ssh" with the label in color
+func (m keyMap) keyHint(action, label, color string) string {
+	return fmt.Sprintf("[dim]%s[%s] %s[-]", tview.Escape("["+keyLabel(m.key(action))+"]"), color, label)
+}
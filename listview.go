@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// EntityListView keeps a tview.List in sync with the entity list, updating rows in place by
+// entity key so refreshes don't flicker or lose the selected host
+type EntityListView struct {
+	*tview.List
+	keys     []string // entityKey of each row, in list order
+	texts    []string // rendered text of each row
+	syncing  bool
+	onChange func(index int)
+}
+
+// NewEntityListView creates an empty list view
+func NewEntityListView() *EntityListView {
+	v := &EntityListView{
+		List: tview.NewList().ShowSecondaryText(false).SetWrapAround(true).SetMainTextColor(tcell.ColorWhite),
+	}
+	v.List.SetChangedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
+		// Intermediate row moves during Sync would report hosts that aren't really selected
+		if !v.syncing && v.onChange != nil {
+			v.onChange(index)
+		}
+	})
+	return v
+}
+
+// OnChange registers the handler called when the highlighted row changes
+func (v *EntityListView) OnChange(handler func(index int)) {
+	v.onChange = handler
+}
+
+// SelectedKey returns the entity key of the highlighted row, or "" for an empty list
+func (v *EntityListView) SelectedKey() string {
+	if cur := v.GetCurrentItem(); cur >= 0 && cur < len(v.keys) {
+		return v.keys[cur]
+	}
+	return ""
+}
+
+// SelectKey highlights the row for key, returning false if no row has it
+func (v *EntityListView) SelectKey(key string) bool {
+	for i, k := range v.keys {
+		if k == key {
+			v.SetCurrentItem(i)
+			return true
+		}
+	}
+	return false
+}
+
+// entityRowText renders the list row for an entity
+func entityRowText(e *Entity) string {
+	status := "[" + theme.OK + "]OK"
+	if e.HasAlert {
+		status = "[" + theme.Alert + "]ALERT"
+	}
+	if e.Flapping {
+		status += " [" + theme.Flapping + "]~FLAPPING"
+	}
+	if e.NotReporting {
+		status += " [" + theme.NotReporting + "]NOT REPORTING"
+	}
+	return fmt.Sprintf("%-15s %s", e.Name, status)
+}
+
+// Sync makes the rows match entities: rows for vanished entities are removed, new ones are
+// inserted, moved ones are relocated and changed ones are re-rendered. The highlighted entity
+// stays highlighted wherever it moves. Must be called on the UI thread.
+func (v *EntityListView) Sync(entities []*Entity) {
+	selectedKey := v.SelectedKey()
+	v.syncing = true
+
+	want := make(map[string]bool, len(entities))
+	for _, e := range entities {
+		want[entityKey(e)] = true
+	}
+
+	// Drop rows for entities that are gone, from the end so indices stay valid
+	for i := len(v.keys) - 1; i >= 0; i-- {
+		if !want[v.keys[i]] {
+			v.removeRow(i)
+		}
+	}
+
+	for i, e := range entities {
+		key, text := entityKey(e), entityRowText(e)
+		if i < len(v.keys) && v.keys[i] == key {
+			if v.texts[i] != text {
+				v.SetItemText(i, text, "")
+				v.texts[i] = text
+			}
+			continue
+		}
+		// Either the entity moved further down (order changed) or it is new
+		for j := i + 1; j < len(v.keys); j++ {
+			if v.keys[j] == key {
+				v.removeRow(j)
+				break
+			}
+		}
+		v.InsertItem(i, text, "", 0, nil)
+		v.keys = append(v.keys[:i], append([]string{key}, v.keys[i:]...)...)
+		v.texts = append(v.texts[:i], append([]string{text}, v.texts[i:]...)...)
+	}
+
+	// Entities sharing a key (e.g. duplicate names without a GUID) can leave surplus rows
+	for len(v.keys) > len(entities) {
+		v.removeRow(len(v.keys) - 1)
+	}
+
+	selected := v.GetCurrentItem()
+	for i, k := range v.keys {
+		if k == selectedKey {
+			selected = i
+			break
+		}
+	}
+	if len(v.keys) > 0 {
+		v.SetCurrentItem(selected)
+	}
+	v.syncing = false
+
+	if len(v.keys) > 0 && v.onChange != nil {
+		v.onChange(v.GetCurrentItem())
+	}
+}
+
+func (v *EntityListView) removeRow(i int) {
+	v.RemoveItem(i)
+	v.keys = append(v.keys[:i], v.keys[i+1:]...)
+	v.texts = append(v.texts[:i], v.texts[i+1:]...)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	logBackups     = 3   // rotated files kept as debug.log.1 ... debug.log.3
+	logViewerLines = 500 // lines kept in memory for the in-app log viewer
+)
+
+var (
+	logMu   sync.Mutex
+	logFile *rotatingWriter
+	// appLog feeds the in-app log viewer; it captures info and above even when the file is off
+	appLog = newLogBuffer(logViewerLines)
+)
+
+// Until setupLogging runs, log only to the viewer so nothing is written over the terminal
+func init() {
+	setupLogging("off", "", 0)
+}
+
+// parseLogLevel maps a log_level value to a slog level; ok is false for "off"
+func parseLogLevel(level string) (lvl slog.Level, ok bool) {
+	switch level {
+	case "debug":
+		return slog.LevelDebug, true
+	case "info":
+		return slog.LevelInfo, true
+	case "warn":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	}
+	return slog.LevelInfo, false
+}
+
+// setupLogging sends the default slog logger to a size-rotated file at path and to the in-app
+// log viewer. It may be called again once the config is loaded; the previous file is closed.
+func setupLogging(level, path string, maxBytes int64) error {
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
+
+	lvl, fileEnabled := parseLogLevel(level)
+	viewerLevel := slog.LevelInfo
+	if fileEnabled && lvl < viewerLevel {
+		viewerLevel = lvl
+	}
+	// Every sink goes through the redactor so logs can be attached to tickets as they are
+	handlers := teeHandler{slog.NewTextHandler(redactWriter{appLog}, &slog.HandlerOptions{Level: viewerLevel})}
+
+	var err error
+	if fileEnabled {
+		var w *rotatingWriter
+		if w, err = openRotatingWriter(path, maxBytes, logBackups); err == nil {
+			logFile = w
+			handlers = append(handlers, slog.NewTextHandler(redactWriter{w}, &slog.HandlerOptions{Level: lvl}))
+		}
+	}
+	slog.SetDefault(slog.New(handlers))
+	return err
+}
+
+// logFor returns the default logger tagged with a component, e.g. "fetch" or "ui"
+func logFor(component string) *slog.Logger {
+	return slog.Default().With("component", component)
+}
+
+// teeHandler sends each record to every handler that accepts its level
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var firstErr error
+	for _, h := range t {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := make(teeHandler, len(t))
+	for i, h := range t {
+		next[i] = h.WithAttrs(attrs)
+	}
+	return next
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	next := make(teeHandler, len(t))
+	for i, h := range t {
+		next[i] = h.WithGroup(name)
+	}
+	return next
+}
+
+// rotatingWriter appends to a log file kept open between writes, rotating it once it would
+// grow past maxBytes
+type rotatingWriter struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	backups  int
+	f        *os.File
+	size     int64
+}
+
+func openRotatingWriter(path string, maxBytes int64, backups int) (*rotatingWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	w := &rotatingWriter{path: path, maxBytes: maxBytes, backups: backups}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f, w.size = f, info.Size()
+	return nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return 0, os.ErrClosed
+	}
+	if w.maxBytes > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxBytes {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate shifts debug.log to debug.log.1, debug.log.1 to debug.log.2 and so on, dropping the oldest
+func (w *rotatingWriter) rotate() error {
+	w.f.Close()
+	w.f = nil
+	os.Remove(fmt.Sprintf("%s.%d", w.path, w.backups))
+	for i := w.backups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+	}
+	if w.backups > 0 {
+		os.Rename(w.path, w.path+".1")
+	} else {
+		os.Remove(w.path)
+	}
+	return w.open()
+}
+
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+// logBuffer keeps the most recent log lines in memory for the log viewer
+type logBuffer struct {
+	mu      sync.Mutex
+	lines   []string
+	max     int
+	onWrite func()
+}
+
+func newLogBuffer(max int) *logBuffer {
+	return &logBuffer{max: max}
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		b.lines = append(b.lines, line)
+	}
+	if len(b.lines) > b.max {
+		b.lines = append([]string(nil), b.lines[len(b.lines)-b.max:]...)
+	}
+	onWrite := b.onWrite
+	b.mu.Unlock()
+
+	if onWrite != nil {
+		onWrite()
+	}
+	return len(p), nil
+}
+
+// Lines returns a copy of the buffered lines, oldest first
+func (b *logBuffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.lines...)
+}
+
+// OnWrite registers fn to be called after each write, from the logging goroutine
+func (b *logBuffer) OnWrite(fn func()) {
+	b.mu.Lock()
+	b.onWrite = fn
+	b.mu.Unlock()
+}
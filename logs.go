@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	// logsTailInterval is how often new log lines are polled while the pane is open
+	logsTailInterval = 10 * time.Second
+	// logsMaxLines caps how many lines are kept in memory for the pane
+	logsMaxLines = 1000
+)
+
+// LogLine is a single record from the Log event type
+type LogLine struct {
+	Timestamp int64 // epoch milliseconds
+	Level     string
+	Message   string
+}
+
+// FetchLogs queries Log events for an entity by hostname or entity.guid, oldest first.
+// When sinceMs is zero the last 30 minutes are returned.
+func FetchLogs(ctx context.Context, config *Config, entity *Entity, sinceMs int64) ([]LogLine, error) {
+	where := fmt.Sprintf("hostname = '%s'", nrqlQuote(entity.Name))
+	if entity.GUID != "" {
+		where = fmt.Sprintf("(%s OR `entity.guid` = '%s')", where, nrqlQuote(entity.GUID))
+	}
+	since := "30 minutes ago"
+	if sinceMs > 0 {
+		since = fmt.Sprintf("%d", sinceMs)
+	}
+	query := fmt.Sprintf("SELECT timestamp, level, `log.level`, severity, message FROM Log WHERE %s SINCE %s LIMIT 500", where, since)
+
+	rows, err := runNRQL(ctx, config, entity.AccountID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// NRQL returns newest first; reverse so the pane reads top to bottom
+	lines := make([]LogLine, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		row := rows[i]
+		line := LogLine{}
+		if ts, ok := row["timestamp"].(float64); ok {
+			line.Timestamp = int64(ts)
+		}
+		for _, key := range []string{"level", "log.level", "severity"} {
+			if lvl, ok := row[key].(string); ok && lvl != "" {
+				line.Level = lvl
+				break
+			}
+		}
+		if msg, ok := row["message"].(string); ok {
+			line.Message = msg
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// severityColor maps a log level to a tview color tag
+func severityColor(level string) string {
+	switch strings.ToLower(level) {
+	case "fatal", "critical", "crit", "emerg", "alert", "error", "err":
+		return "red"
+	case "warn", "warning":
+		return "yellow"
+	case "debug", "trace":
+		return "gray"
+	}
+	return "white"
+}
+
+// LogsPanel tails Log events for the selected host
+type LogsPanel struct {
+	root   *tview.Flex
+	view   *tview.TextView
+	filter *tview.InputField
+	status *tview.TextView
+
+	ctx     context.Context // the app's context, cancelled on quit
+	config  *Config
+	app     *tview.Application
+	onClose func()
+
+	entity *Entity
+	lines  []LogLine
+	follow bool
+	cancel context.CancelFunc // stops the running tail
+}
+
+// NewLogsPanel builds the logs pane; onClose is called on the UI thread when the user leaves it
+func NewLogsPanel(ctx context.Context, config *Config, app *tview.Application, onClose func()) *LogsPanel {
+	p := &LogsPanel{
+		ctx:     ctx,
+		config:  config,
+		app:     app,
+		onClose: onClose,
+		follow:  true,
+	}
+
+	p.view = tview.NewTextView().SetDynamicColors(true).SetScrollable(true).SetWrap(true)
+	p.view.SetBorder(true)
+	p.filter = tview.NewInputField().SetLabel("Filter: ").SetFieldBackgroundColor(tcell.ColorBlack)
+	p.status = tview.NewTextView().SetDynamicColors(true)
+
+	help := tview.NewTextView().SetDynamicColors(true).
+		SetText("[dim]↑↓/PgUp/PgDn[-] scroll | [dim]/[-] filter | [dim]f[-] follow | [dim]Esc/q[-] close")
+
+	p.root = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(p.status, 1, 0, false).
+		AddItem(p.view, 0, 1, true).
+		AddItem(p.filter, 1, 0, false).
+		AddItem(help, 1, 0, false)
+
+	p.view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEsc:
+			p.Close()
+			return nil
+		case tcell.KeyUp, tcell.KeyPgUp, tcell.KeyHome:
+			// Scrolling back pauses follow so new lines don't yank the view
+			p.follow = false
+			p.updateStatus()
+			return event
+		case tcell.KeyRune:
+			switch event.Rune() {
+			case 'q', 'Q':
+				p.Close()
+				return nil
+			case '/':
+				p.app.SetFocus(p.filter)
+				return nil
+			case 'f', 'F':
+				p.follow = !p.follow
+				if p.follow {
+					p.view.ScrollToEnd()
+				}
+				p.updateStatus()
+				return nil
+			}
+		}
+		return event
+	})
+
+	p.filter.SetChangedFunc(func(text string) {
+		p.render()
+	})
+	p.filter.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEsc {
+			p.filter.SetText("")
+		}
+		p.app.SetFocus(p.view)
+	})
+
+	return p
+}
+
+// Open starts tailing logs for the given entity
+func (p *LogsPanel) Open(entity *Entity) {
+	p.stopTail()
+	p.entity = entity
+	p.lines = nil
+	p.follow = true
+	p.view.Clear()
+	p.view.SetTitle(fmt.Sprintf(" Logs: %s ", tview.Escape(entity.Name)))
+	p.app.SetFocus(p.view)
+
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.cancel = cancel
+	p.status.SetText("[yellow]⟳ Loading logs...")
+	go p.tail(ctx, entity)
+}
+
+// Close stops tailing and returns to the main view
+func (p *LogsPanel) Close() {
+	p.stopTail()
+	p.onClose()
+}
+
+func (p *LogsPanel) stopTail() {
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+}
+
+// tail polls for new lines until ctx is cancelled; results are applied on the UI thread
+func (p *LogsPanel) tail(ctx context.Context, entity *Entity) {
+	ticker := time.NewTicker(logsTailInterval)
+	defer ticker.Stop()
+
+	var sinceMs int64
+	for {
+		lines, err := FetchLogs(ctx, p.config, entity, sinceMs)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			for _, l := range lines {
+				if l.Timestamp > sinceMs {
+					sinceMs = l.Timestamp
+				}
+			}
+		} else {
+			logFor("logs").Warn("tailing logs failed", "err", err)
+		}
+
+		p.app.QueueUpdateDraw(func() {
+			if ctx.Err() != nil {
+				// Panel was closed or reopened for another host meanwhile
+				return
+			}
+			if err != nil {
+				p.status.SetText(fmt.Sprintf("[red]✗ %s", tview.Escape(err.Error())))
+				return
+			}
+			p.appendLines(lines)
+			p.render()
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// appendLines adds lines not already shown; SINCE is inclusive so the boundary repeats
+func (p *LogsPanel) appendLines(lines []LogLine) {
+	seen := make(map[LogLine]bool)
+	if n := len(p.lines); n > 0 {
+		last := p.lines[n-1].Timestamp
+		for i := n - 1; i >= 0 && p.lines[i].Timestamp == last; i-- {
+			seen[p.lines[i]] = true
+		}
+	}
+	for _, l := range lines {
+		if !seen[l] {
+			p.lines = append(p.lines, l)
+		}
+	}
+	if len(p.lines) > logsMaxLines {
+		p.lines = p.lines[len(p.lines)-logsMaxLines:]
+	}
+}
+
+func (p *LogsPanel) render() {
+	q := strings.ToLower(p.filter.GetText())
+	var b strings.Builder
+	for _, l := range p.lines {
+		if q != "" && !strings.Contains(strings.ToLower(l.Message), q) && !strings.Contains(strings.ToLower(l.Level), q) {
+			continue
+		}
+		ts := time.UnixMilli(l.Timestamp).Format("15:04:05")
+		level := l.Level
+		if level == "" {
+			level = "-"
+		}
+		fmt.Fprintf(&b, "[dim]%s[-] [%s]%-5s %s[-]\n", ts, severityColor(l.Level), tview.Escape(strings.ToUpper(level)), tview.Escape(l.Message))
+	}
+	p.view.SetText(b.String())
+	if p.follow {
+		p.view.ScrollToEnd()
+	}
+	p.updateStatus()
+}
+
+func (p *LogsPanel) updateStatus() {
+	follow := "[green]following[-]"
+	if !p.follow {
+		follow = "[yellow]paused[-]"
+	}
+	filter := ""
+	if f := p.filter.GetText(); f != "" {
+		filter = fmt.Sprintf(" | filter: [teal]%s[-]", tview.Escape(f))
+	}
+	p.status.SetText(fmt.Sprintf("%d lines | %s | tail every %s%s", len(p.lines), follow, logsTailInterval, filter))
+}
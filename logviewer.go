@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// AppLogPanel shows Osiris' own recent log lines, following new ones as they are written
+type AppLogPanel struct {
+	root   *tview.Flex
+	view   *tview.TextView
+	status *tview.TextView
+
+	config  *Config
+	app     *tview.Application
+	onClose func()
+
+	open   atomic.Bool
+	follow bool
+	wake   chan struct{}
+}
+
+// NewAppLogPanel builds the log viewer; onClose is called on the UI thread when the user leaves it
+func NewAppLogPanel(config *Config, app *tview.Application, onClose func()) *AppLogPanel {
+	p := &AppLogPanel{
+		config:  config,
+		app:     app,
+		onClose: onClose,
+		follow:  true,
+		wake:    make(chan struct{}, 1),
+	}
+
+	p.view = tview.NewTextView().SetDynamicColors(true).SetScrollable(true).SetWrap(true)
+	p.view.SetBorder(true).SetTitle(" Osiris log ")
+	p.status = tview.NewTextView().SetDynamicColors(true)
+
+	help := tview.NewTextView().SetDynamicColors(true).
+		SetText("[dim]↑↓/PgUp/PgDn[-] scroll | [dim]f[-] follow | [dim]Esc/q/d[-] close")
+
+	p.root = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(p.status, 1, 0, false).
+		AddItem(p.view, 0, 1, true).
+		AddItem(help, 1, 0, false)
+
+	p.view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEsc:
+			p.Close()
+			return nil
+		case tcell.KeyUp, tcell.KeyPgUp, tcell.KeyHome:
+			p.follow = false
+			p.updateStatus()
+			return event
+		case tcell.KeyRune:
+			switch event.Rune() {
+			case 'q', 'Q', 'd', 'D':
+				p.Close()
+				return nil
+			case 'f', 'F':
+				p.follow = !p.follow
+				if p.follow {
+					p.view.ScrollToEnd()
+				}
+				p.updateStatus()
+				return nil
+			}
+		}
+		return event
+	})
+
+	// Log calls can come from the UI thread itself, so only signal here and redraw from a
+	// separate goroutine rather than queueing an update inside the write
+	appLog.OnWrite(func() {
+		select {
+		case p.wake <- struct{}{}:
+		default:
+		}
+	})
+	go func() {
+		for range p.wake {
+			if p.open.Load() {
+				p.app.QueueUpdateDraw(p.render)
+			}
+		}
+	}()
+
+	return p
+}
+
+// Open shows the log viewer scrolled to the newest line
+func (p *AppLogPanel) Open() {
+	p.open.Store(true)
+	p.follow = true
+	p.render()
+	p.app.SetFocus(p.view)
+}
+
+// Close returns to the main view
+func (p *AppLogPanel) Close() {
+	p.open.Store(false)
+	p.onClose()
+}
+
+func (p *AppLogPanel) render() {
+	lines := appLog.Lines()
+	p.view.Clear()
+	for _, line := range lines {
+		color := "white"
+		switch {
+		case strings.Contains(line, "level=ERROR"):
+			color = "red"
+		case strings.Contains(line, "level=WARN"):
+			color = "yellow"
+		case strings.Contains(line, "level=DEBUG"):
+			color = "gray"
+		}
+		fmt.Fprintf(p.view, "[%s]%s[-]\n", color, tview.Escape(line))
+	}
+	if p.follow {
+		p.view.ScrollToEnd()
+	}
+	p.updateStatus()
+}
+
+func (p *AppLogPanel) updateStatus() {
+	msg := fmt.Sprintf("[white]%d recent lines", len(appLog.Lines()))
+	if p.config.LogLevel != "off" {
+		msg += fmt.Sprintf(" · level %s · file %s", p.config.LogLevel, tview.Escape(p.config.LogFile))
+	} else {
+		msg += " · file logging off (set log_level to keep a log file)"
+	}
+	if p.follow {
+		msg += " [green]following"
+	} else {
+		msg += " [yellow]paused (f to follow)"
+	}
+	p.status.SetText(msg)
+}
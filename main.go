@@ -2,13 +2,15 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
-	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
@@ -16,41 +18,124 @@ import (
 )
 
 type AppState struct {
-	entities          []*Entity
-	lastRefresh       time.Time
-	refreshInProgress bool
-	mu                sync.Mutex
-	selectedIndex     int
-	errMsg            string
-	searchQuery       string
-	lastSearchPos     int
+	snapshot      atomic.Pointer[Snapshot] // swapped whole under mu, read without it
+	mu            sync.Mutex
+	cancelRefresh context.CancelFunc // cancels the most recent refresh
+	selectedKey   string             // entityKey of the highlighted entity
+	searchQuery   string
+	lastSearchKey string
+	metrics       *HostMetrics
+	metricsWake   chan struct{}
+	alerts        *AlertTracker
+	notifier      *Notifier
+	webhooks      *WebhookForwarder
+	eventLog      io.Writer // structured event lines in headless mode, nil otherwise
+	exporter      *Exporter
+	scheduler     *RefreshScheduler
 }
 
 func main() {
-	// Check for --debug flag
-	for _, arg := range os.Args[1:] {
-		if arg == "--debug" {
-			DebugEnabled = true
-			break
+	// Global flags come before an optional one-shot subcommand
+	opts, args, err := parseFlags(os.Args[1:], os.Stderr)
+	if err != nil {
+		exitOnFlagError(err)
+	}
+	if opts.Version {
+		fmt.Println("osiris " + version)
+		return
+	}
+
+	// Enable logging early when asked on the command line so config loading is traced too
+	if opts.LogLevel != "" {
+		logPath := opts.LogFile
+		if logPath == "" {
+			logPath = getOsirisPath("debug.log")
+		}
+		setupLogging(opts.LogLevel, logPath, 5<<20)
+	}
+	config := LoadConfig(opts.ConfigPath)
+	// First run: offer to set up an API key and accounts instead of falling back to demo data
+	if config.Path == "" && len(args) == 0 && !opts.Demo && !opts.Headless {
+		target := opts.ConfigPath
+		if target == "" {
+			target = getConfigPath()
+		}
+		if path, err := runSetupWizard(target); err != nil {
+			fmt.Fprintln(os.Stderr, "osiris: setup: "+err.Error())
+		} else if path != "" {
+			fmt.Fprintln(os.Stderr, "osiris: wrote "+path)
+			config = LoadConfig(path)
+		} else {
+			// Skipping setup starts with demo data
+			config.Demo = true
+		}
+	}
+	// config check reports problems itself, so it runs before they would stop startup
+	if len(args) > 0 && args[0] == "config" {
+		os.Exit(cmdConfig(config, args[1:]))
+	}
+	if len(config.Errors) > 0 {
+		for _, msg := range config.Errors {
+			fmt.Fprintln(os.Stderr, "osiris: "+msg)
 		}
+		fmt.Fprintln(os.Stderr, "osiris: fix the config file and try again; osiris config check re-validates it")
+		os.Exit(exitError)
+	}
+	if err := opts.Apply(config); err != nil {
+		exitOnFlagError(err)
+	}
+	keys = newKeyMap(config.Keys)
+	theme = config.Themes[config.Theme]
+	if err := resolveAPIKey(config); err != nil {
+		config.Warnings = append(config.Warnings, "could not read the API key: "+err.Error())
+	}
+	configureRedaction(config)
+	if err := setupLogging(config.LogLevel, config.LogFile, int64(config.LogMaxSize)<<20); err != nil {
+		fmt.Fprintln(os.Stderr, "osiris: log file: "+err.Error())
+	}
+	logFor("app").Info("osiris started", "version", version, "api_key_set", config.APIKey != "", "account_id_set", config.AccountID != "")
+	for _, warning := range config.Warnings {
+		logFor("config").Warn(warning)
+		fmt.Fprintln(os.Stderr, "osiris: warning: "+warning)
+	}
+
+	if isCommand(args) {
+		os.Exit(runCommand(config, args))
+	}
+
+	state := newAppState(config)
+
+	// Show the last cached snapshot immediately; the first live refresh replaces it
+	if cache, err := loadCache(config); err == nil {
+		state.update(func(next *Snapshot) {
+			next.Entities = cache.Entities
+			next.RefreshedAt = cache.SavedAt
+			next.Stale = true
+		})
 	}
 
-	config := LoadConfig()
-	debugLog("=== OSIRIS STARTED ===")
-	debugLog("API Key set: " + fmt.Sprintf("%v", config.APIKey != ""))
-	debugLog("Account ID set: " + fmt.Sprintf("%v", config.AccountID != ""))
+	// Cancelled on quit so in-flight requests are abandoned rather than outliving the UI
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	state := &AppState{lastRefresh: time.Now()}
+	if opts.Headless {
+		runHeadless(ctx, state, config)
+		return
+	}
+
+	startHTTPServer(state, config)
 
 	app := tview.NewApplication()
+	state.notifier.AttachScreen(app)
 
 	// Main list view
-	list := tview.NewList().ShowSecondaryText(false).SetWrapAround(true)
+	list := NewEntityListView()
 
 	// Status bar
-	statusText := tview.NewTextView().SetDynamicColors(true)
-	statusText.SetText("[yellow]⟳ Loading entities from New Relic...")
-	statusText.SetBorder(false)
+	statusBar := NewStatusBar(state, config)
+	if len(config.Warnings) > 0 {
+		statusBar.Flash("[yellow]⚠ " + tview.Escape(strings.Join(config.Warnings, "; ")))
+	}
 
 	// Details view for selected item
 	detailsText := tview.NewTextView().SetDynamicColors(true)
@@ -58,33 +143,81 @@ func main() {
 
 	// Flex layout
 	flex := tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(statusText, 1, 0, false).
+		AddItem(statusBar, 1, 0, false).
 		AddItem(list, 0, 1, true).
-		AddItem(detailsText, 5, 0, false)
+		AddItem(detailsText, 14, 0, false)
+
+	// Pages hold the main view plus full-screen panels such as the NRQL console
+	pages := tview.NewPages()
+	nrqlConsole := NewNRQLConsole(ctx, config, app, func() {
+		pages.SwitchToPage("main")
+		app.SetFocus(list)
+	})
+	logsPanel := NewLogsPanel(ctx, config, app, func() {
+		pages.SwitchToPage("main")
+		app.SetFocus(list)
+	})
+	historyPanel := NewHistoryPanel(ctx, config, app, func() {
+		pages.SwitchToPage("main")
+		app.SetFocus(list)
+	})
+	appLogPanel := NewAppLogPanel(config, app, func() {
+		pages.SwitchToPage("main")
+		app.SetFocus(list)
+	})
 
 	// Start heartbeat for debugging
-	go startHeartbeat()
+	go startHeartbeat(ctx)
 
-	// Initial fetch
-	go refreshEntities(state, config, list, statusText, detailsText, app)
+	// Live metrics for the selected host
+	go refreshMetrics(ctx, state, config, detailsText, app)
 
-	// Auto-refresh ticker
-	ticker := time.NewTicker(time.Duration(config.RefreshInterval) * time.Second)
-	defer ticker.Stop()
+	// Redraw the list from state; safe to call from any goroutine
+	redraw := func() {
+		app.QueueUpdateDraw(func() {
+			updateListView(list, state, statusBar)
+		})
+	}
+
+	// Initial fetch, drawing any cached entities while it runs
+	go redraw()
+	go refreshEntities(ctx, state, config, true, redraw)
+
+	// Tick the status bar so data age and the refresh countdown stay current
 	go func() {
-		for range ticker.C {
-			refreshEntities(state, config, list, statusText, detailsText, app)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				app.QueueUpdateDraw(statusBar.Refresh)
+			}
 		}
 	}()
 
+	// Auto-refresh on the adaptive schedule; a timer tick never cancels a refresh in flight
+	go state.scheduler.Run(ctx, func() {
+		refreshEntities(ctx, state, config, false, redraw)
+	})
+
+	// Any key press counts as activity, so an unattended terminal refreshes less often
+	state.scheduler.Activity()
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		state.scheduler.Activity()
+		return event
+	})
+
 	// List selection handler (activated/Enter)
 	list.SetSelectedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
-		showDetails(index, state, detailsText)
+		showDetails(state, detailsText)
 	})
 
-	// Track highlight changes (arrow keys)
-	list.SetChangedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
-		showDetails(index, state, detailsText)
+	// Track highlight changes (arrow keys, and the selected row moving during a refresh)
+	list.OnChange(func(index int) {
+		state.Select(list.SelectedKey())
+		showDetails(state, detailsText)
 	})
 
 	// Input handler
@@ -96,14 +229,14 @@ func main() {
 				return nil
 			}
 		case tcell.KeyRune:
-			switch event.Rune() {
-			case 'q', 'Q':
+			switch keys.action(event.Rune()) {
+			case "quit":
 				app.Stop()
 				return nil
-			case ' ':
-				go refreshEntities(state, config, list, statusText, detailsText, app)
+			case "refresh":
+				go refreshEntities(ctx, state, config, true, redraw)
 				return nil
-			case '/':
+			case "search":
 				app.Suspend(func() {
 					fmt.Print("Search for server: ")
 					reader := bufio.NewReader(os.Stdin)
@@ -111,42 +244,72 @@ func main() {
 					q = strings.TrimSpace(q)
 					state.mu.Lock()
 					state.searchQuery = q
-					state.lastSearchPos = -1
+					state.lastSearchKey = ""
 					state.mu.Unlock()
 				})
-				if state.searchQuery != "" {
-					found := findNextMatch(state)
-					if found >= 0 {
-						list.SetCurrentItem(found)
-						showDetails(found, state, detailsText)
-					}
+				if key := findNextMatch(state); key != "" {
+					list.SelectKey(key)
 				}
 				return nil
-			case 'n', 'N':
-				if state.searchQuery != "" {
-					found := findNextMatch(state)
-					if found >= 0 {
-						list.SetCurrentItem(found)
-						showDetails(found, state, detailsText)
+			case "nrql":
+				// NRQL console, with the selected entity available as {{name}}/{{guid}}
+				pages.SwitchToPage("nrql")
+				nrqlConsole.Open(state.Selected())
+				return nil
+			case "logs":
+				// Tail logs for the selected host
+				if entity := state.Selected(); entity != nil {
+					pages.SwitchToPage("logs")
+					logsPanel.Open(entity)
+				}
+				return nil
+			case "history":
+				// Incident timeline for the selected host
+				if entity := state.Selected(); entity != nil {
+					pages.SwitchToPage("history")
+					historyPanel.Open(entity)
+				}
+				return nil
+			case "app_log":
+				// Osiris' own log
+				pages.SwitchToPage("applog")
+				appLogPanel.Open()
+				return nil
+			case "export":
+				// Export the current list for pasting into tickets
+				entities := state.Snapshot().Entities
+				path, data, err := exportEntities(entities, config.ExportFormat, config.ExportDir, "")
+				if err != nil {
+					statusBar.Flash(fmt.Sprintf("[red]✗ Export failed: %s", tview.Escape(err.Error())))
+					return nil
+				}
+				msg := fmt.Sprintf("[green]✓[white] Exported %d entities to %s", len(entities), tview.Escape(path))
+				if config.ExportClipboard {
+					if err := copyToClipboard(data); err != nil {
+						msg += fmt.Sprintf(" [yellow](clipboard: %s)", tview.Escape(err.Error()))
+					} else {
+						msg += " and copied to clipboard"
 					}
 				}
+				statusBar.Flash(msg)
+				return nil
+			case "next_match":
+				if key := findNextMatch(state); key != "" {
+					list.SelectKey(key)
+				}
 				return nil
-			case 's', 'S':
+			case "ssh":
 				// SSH
-				state.mu.Lock()
-				if state.selectedIndex >= 0 && state.selectedIndex < len(state.entities) {
-					entity := state.entities[state.selectedIndex]
-					state.mu.Unlock()
-
-					debugLog(fmt.Sprintf("Launching SSH to %s", entity.Name))
-					debugLog("about to suspend (SSH)")
+				if entity := state.Selected(); entity != nil {
+					logFor("ui").Info("launching SSH", "entity", entity.Name)
+					logFor("ui").Debug("about to suspend (SSH)")
 					app.Suspend(func() {
 						defer func() {
 							if r := recover(); r != nil {
-								debugLog(fmt.Sprintf("panic in SSH suspend: %v", r))
+								logFor("ui").Error("panic in SSH suspend", "panic", r)
 							}
 						}()
-						debugLog("in suspend (SSH): preparing to exec")
+						logFor("ui").Debug("in suspend (SSH): preparing to exec")
 						fmt.Fprintf(os.Stderr, "\n[osiris] Launching SSH to %s\n", entity.Name)
 						fmt.Fprintf(os.Stderr, "[osiris] Type 'exit' or Ctrl+D to return to osiris\n\n")
 						fmt.Fprintf(os.Stderr, "Enter the ssh username: ")
@@ -157,43 +320,37 @@ func main() {
 						execCmd.Stdout = os.Stdout
 						execCmd.Stderr = os.Stderr
 						if err := execCmd.Run(); err != nil {
-							debugLog("SSH error: " + err.Error())
+							logFor("ui").Warn("SSH exited with an error", "err", err)
 						}
-						debugLog("in suspend (SSH): exec.Run returned")
+						logFor("ui").Debug("in suspend (SSH): exec.Run returned")
 					})
 
-					debugLog("returned from suspend (SSH)")
+					logFor("ui").Debug("returned from suspend (SSH)")
 					// small pause to allow terminal to be restored
 					time.Sleep(100 * time.Millisecond)
 					go func() {
-						debugLog("attempting suspend-resume to force terminal reset (SSH)")
+						logFor("ui").Debug("attempting suspend-resume to force terminal reset (SSH)")
 						app.Suspend(func() {})
 						time.Sleep(50 * time.Millisecond)
 						app.QueueUpdateDraw(func() {
-							debugLog("queueing redraw after SSH suspend (via suspend-resume)")
-							updateListView(list, state, statusText, detailsText, app)
+							logFor("ui").Debug("queueing redraw after SSH suspend (via suspend-resume)")
+							updateListView(list, state, statusBar)
 						})
 					}()
-					return nil
 				}
-				state.mu.Unlock()
 				return nil
-			case 'r', 'R':
+			case "rdp":
 				// RDP
-				state.mu.Lock()
-				if state.selectedIndex >= 0 && state.selectedIndex < len(state.entities) {
-					entity := state.entities[state.selectedIndex]
-					state.mu.Unlock()
-
-					debugLog(fmt.Sprintf("Launching RDP to %s", entity.Name))
-					debugLog("about to suspend (RDP)")
+				if entity := state.Selected(); entity != nil {
+					logFor("ui").Info("launching RDP", "entity", entity.Name)
+					logFor("ui").Debug("about to suspend (RDP)")
 					app.Suspend(func() {
 						defer func() {
 							if r := recover(); r != nil {
-								debugLog(fmt.Sprintf("panic in RDP suspend: %v", r))
+								logFor("ui").Error("panic in RDP suspend", "panic", r)
 							}
 						}()
-						debugLog("in suspend (RDP): preparing to exec")
+						logFor("ui").Debug("in suspend (RDP): preparing to exec")
 						fmt.Fprintf(os.Stderr, "\n[osiris] Launching RDP to %s\n", entity.Name)
 
 						var execCmd *exec.Cmd
@@ -211,29 +368,27 @@ func main() {
 						execCmd.Stdout = os.Stdout
 						execCmd.Stderr = os.Stderr
 						if err := execCmd.Run(); err != nil {
-							debugLog("RDP error: " + err.Error())
+							logFor("ui").Warn("RDP exited with an error", "err", err)
 							fmt.Fprintf(os.Stderr, "[osiris] RDP failed: %v\n", err)
 						}
-						debugLog("in suspend (RDP): exec.Run returned")
+						logFor("ui").Debug("in suspend (RDP): exec.Run returned")
 					})
 
-					debugLog("returned from suspend (RDP)")
+					logFor("ui").Debug("returned from suspend (RDP)")
 					// small pause to allow terminal to be restored
 					time.Sleep(100 * time.Millisecond)
 					// Try a suspend-resume cycle in a background goroutine to force tview/tcell to reinitialise
 					go func() {
-						debugLog("attempting suspend-resume to force terminal reset (RDP)")
+						logFor("ui").Debug("attempting suspend-resume to force terminal reset (RDP)")
 						app.Suspend(func() {})
 						// brief pause after suspend-resume
 						time.Sleep(50 * time.Millisecond)
 						app.QueueUpdateDraw(func() {
-							debugLog("queueing redraw after RDP suspend (via suspend-resume)")
-							updateListView(list, state, statusText, detailsText, app)
+							logFor("ui").Debug("queueing redraw after RDP suspend (via suspend-resume)")
+							updateListView(list, state, statusBar)
 						})
 					}()
-					return nil
 				}
-				state.mu.Unlock()
 				return nil
 			}
 		}
@@ -242,7 +397,19 @@ func main() {
 
 	// Title
 	titleText := tview.NewTextView().SetDynamicColors(true).
-		SetText("[::b][darkgreen]New Relic Incident Console[-] | [dim]↑↓[yellow] navigate[-] | [dim][s[][purple] ssh[-] | [dim][r[][blue] rdp[-] | [dim][space[][teal] ⟳ refresh[-] | [dim][q[][red] quit[-]")
+		SetText(strings.Join([]string{
+			"[::b][darkgreen]New Relic Incident Console[-]",
+			"[dim]↑↓[yellow] navigate[-]",
+			keys.keyHint("ssh", "ssh", "purple"),
+			keys.keyHint("rdp", "rdp", "blue"),
+			keys.keyHint("refresh", "⟳ refresh", "teal"),
+			keys.keyHint("logs", "logs", "green"),
+			keys.keyHint("history", "history", "yellow"),
+			keys.keyHint("export", "export", "teal"),
+			keys.keyHint("app_log", "log", "gray"),
+			keys.keyHint("nrql", "nrql", "orange"),
+			keys.keyHint("quit", "quit", "red"),
+		}, " | "))
 
 	titleBox := tview.NewFlex().SetDirection(tview.FlexColumn).AddItem(titleText, 0, 1, false)
 	titleBox.SetBorderAttributes(tcell.AttrBold)
@@ -251,191 +418,382 @@ func main() {
 		AddItem(titleBox, 2, 0, false).
 		AddItem(flex, 0, 1, true)
 
-	if err := app.SetRoot(mainFlex, true).Run(); err != nil {
+	pages.AddPage("main", mainFlex, true, true).
+		AddPage("nrql", nrqlConsole.root, true, false).
+		AddPage("logs", logsPanel.root, true, false).
+		AddPage("history", historyPanel.root, true, false).
+		AddPage("applog", appLogPanel.root, true, false)
+
+	if err := app.SetRoot(pages, true).Run(); err != nil {
 		panic(err)
 	}
 }
 
-// showDetails updates the detailsText for a given index and stores selectedIndex
-func showDetails(index int, state *AppState, detailsText *tview.TextView) {
+func newAppState(config *Config) *AppState {
+	state := &AppState{
+		metricsWake: make(chan struct{}, 1),
+		alerts:      NewAlertTracker(config.FlapThreshold, time.Duration(config.FlapWindow)*time.Minute),
+		notifier:    NewNotifier(config),
+		webhooks:    NewWebhookForwarder(config),
+		exporter:    NewExporter(accountIDs(config)),
+		scheduler:   NewRefreshScheduler(time.Duration(config.RefreshInterval)*time.Second, config.AdaptiveRefresh),
+	}
+	state.snapshot.Store(&Snapshot{RefreshedAt: time.Now()})
+	return state
+}
+
+// showDetails renders the selected entity into detailsText
+func showDetails(state *AppState, detailsText *tview.TextView) {
 	detailsText.Clear()
+	entity := state.Selected()
+	if entity == nil {
+		return
+	}
 	state.mu.Lock()
-	if index < len(state.entities) {
-		state.selectedIndex = index
-		entity := state.entities[index]
-		state.mu.Unlock()
+	metrics := state.metrics
+	state.mu.Unlock()
+	if metrics != nil && metrics.GUID != entity.GUID {
+		metrics = nil
+	}
 
-		if entity.HasAlert {
-			fmt.Fprintf(detailsText, "[red]🔴 ALERT[white]\n")
-			fmt.Fprintf(detailsText, "[red]%s[white]\n", entity.AlertType)
-			fmt.Fprintf(detailsText, "%s\n\n", entity.AlertMessage)
-			fmt.Fprintf(detailsText, "[yellow]Press 's' for SSH or 'r' for RDP")
-		} else {
-			fmt.Fprintf(detailsText, "[green]✓ Status: OK[white]\n")
-			fmt.Fprintf(detailsText, "No active alerts")
-		}
+	if entity.HasAlert {
+		fmt.Fprintf(detailsText, "[%s]🔴 ALERT[white]\n", theme.Alert)
+		fmt.Fprintf(detailsText, "[%s]%s[white]\n", theme.Alert, entity.AlertType)
+		fmt.Fprintf(detailsText, "%s\n\n", entity.AlertMessage)
+		fmt.Fprintf(detailsText, "[yellow]Press '%s' for SSH or '%s' for RDP", tview.Escape(keyLabel(keys.key("ssh"))), tview.Escape(keyLabel(keys.key("rdp"))))
 	} else {
-		state.mu.Unlock()
+		fmt.Fprintf(detailsText, "[%s]✓ Status: OK[white]\n", theme.OK)
+		fmt.Fprintf(detailsText, "No active alerts")
+	}
+	if entity.NotReporting {
+		fmt.Fprintf(detailsText, "\n[%s]⚠ Not reporting: no data from the agent[white]", theme.NotReporting)
+	}
+	if entity.OS != "" || entity.Tags["instanceType"] != "" {
+		fmt.Fprintf(detailsText, "\n[dim]%s[white]", strings.TrimSpace(entity.OS+" "+entity.Tags["instanceType"]))
+	}
+	if entity.Flapping {
+		fmt.Fprintf(detailsText, "\n[%s]⚠ Flapping: %d state changes in the last %d minutes[white]", theme.Flapping, entity.FlapCount, int(state.alerts.Window().Minutes()))
+	}
+
+	if entity.GUID != "" {
+		if metrics == nil {
+			requestMetrics(state)
+		}
+		fmt.Fprintf(detailsText, "\n\n")
+		writeMetrics(detailsText, metrics)
 	}
 }
 
-// findNextMatch searches for the next entity matching state's searchQuery
-func findNextMatch(state *AppState) int {
-	state.mu.Lock()
-	defer state.mu.Unlock()
-	q := strings.ToLower(state.searchQuery)
-	if q == "" {
-		return -1
-	}
-	start := state.lastSearchPos + 1
-	if start < 0 {
-		start = 0
-	}
-	n := len(state.entities)
-	for i := 0; i < n; i++ {
-		idx := (start + i) % n
-		if strings.Contains(strings.ToLower(state.entities[idx].Name), q) {
-			state.lastSearchPos = idx
-			return idx
+// requestMetrics wakes the metrics refresher without blocking the UI thread
+func requestMetrics(state *AppState) {
+	select {
+	case state.metricsWake <- struct{}{}:
+	default:
+	}
+}
+
+// refreshMetrics fetches metrics for the selected host on an interval, or sooner when woken,
+// until ctx is cancelled. A wake during a fetch means the selection moved, so the fetch for the
+// old host is cancelled and one for the new host starts.
+func refreshMetrics(ctx context.Context, state *AppState, config *Config, detailsText *tview.TextView, app *tview.Application) {
+	ticker := time.NewTicker(metricsRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-state.metricsWake:
 		}
+
+		entity := state.Selected()
+		if entity == nil || entity.GUID == "" {
+			continue
+		}
+
+		fetchCtx, cancel := context.WithCancel(ctx)
+		done := make(chan *HostMetrics, 1)
+		go func(account, guid string) {
+			done <- FetchHostMetrics(fetchCtx, config, account, guid)
+		}(entity.AccountID, entity.GUID)
+		var m *HostMetrics
+		select {
+		case m = <-done:
+		case <-state.metricsWake:
+			requestMetrics(state)
+		case <-ctx.Done():
+		}
+		cancel()
+		if m == nil {
+			continue
+		}
+
+		state.mu.Lock()
+		state.metrics = m
+		state.mu.Unlock()
+
+		app.QueueUpdateDraw(func() {
+			showDetails(state, detailsText)
+		})
 	}
-	return -1
 }
 
 // startHeartbeat writes a periodic heartbeat to the debug log to help detect hangs
-func startHeartbeat() {
+func startHeartbeat(ctx context.Context) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
 	for {
-		debugLog("heartbeat")
-		time.Sleep(5 * time.Second)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			logFor("app").Debug("heartbeat")
+		}
 	}
 }
 
-func refreshEntities(state *AppState, config *Config, list *tview.List, statusText *tview.TextView, detailsText *tview.TextView, app *tview.Application) {
-	state.mu.Lock()
-	if state.refreshInProgress {
-		state.mu.Unlock()
+// refreshEntities fetches entities and incidents and publishes a new snapshot once incidents
+// are merged, then again as details arrive; onUpdate is called after each publish so the caller
+// can redraw. Entities are never modified once published: the incident stage works on its own
+// copies. A manual refresh sets supersede to cancel one already running; cancelling parent (on
+// quit) abandons the refresh.
+func refreshEntities(parent context.Context, state *AppState, config *Config, supersede bool, onUpdate func()) {
+	ctx, ok := state.beginRefresh(parent, supersede)
+	if !ok {
 		return
 	}
-	state.refreshInProgress = true
-	state.mu.Unlock()
+	state.scheduler.Started()
 
 	// Fetch fresh data
-	result := FetchEntities(config)
-	newEntities := result.Entities
+	started := time.Now()
+	result := FetchEntities(ctx, config)
+	if ctx.Err() != nil {
+		logFor("refresh").Debug("abandoned while fetching entities")
+		return
+	}
+	state.exporter.ObserveFetch("entities", time.Since(started), classifyFetchError(result.Error))
+	newEntities := filterEntities(result.Entities, config.Filter)
+	carryDetails(newEntities, state.Snapshot().Entities)
+
+	// When New Relic is unreachable, show the last cached snapshot, or else nothing: there are
+	// no alerts to look up, and placeholder hosts must never reach logs, exports or the API
+	if result.Error != "" {
+		state.scheduler.Failed()
+		cache, cacheErr := loadCache(config)
+		if !state.publish(ctx, func(next *Snapshot) {
+			if cacheErr == nil {
+				next.Entities = cache.Entities
+				next.RefreshedAt = cache.SavedAt
+				next.Stale = true
+			} else {
+				next.Entities = []*Entity{}
+				next.Stale = false
+			}
+			next.Error = result.Error
+			next.Refreshing = false
+		}) {
+			return
+		}
+		if state.eventLog != nil {
+			fields := map[string]interface{}{"error": result.Error}
+			if cacheErr == nil {
+				fields["cached_from"] = cache.SavedAt.UTC().Format(time.RFC3339)
+			}
+			writeEvent(state.eventLog, "error", "fetch_error", fields)
+		}
+		onUpdate()
+		return
+	}
 
-	state.mu.Lock()
-	state.entities = newEntities
-	state.errMsg = result.Error
-	state.lastRefresh = time.Now()
-	state.refreshInProgress = false
-	state.mu.Unlock()
+	// Entities are only published once incidents are merged into them: publishing them
+	// earlier would show alerting hosts as OK and reorder the list twice
+	if !state.publish(ctx, func(next *Snapshot) {
+		if len(newEntities) == 0 {
+			next.Entities = newEntities
+			next.Stale = false
+			next.RefreshedAt = time.Now()
+		}
+		next.Error = ""
+		next.Refreshing = false
+	}) {
+		return
+	}
+	if len(newEntities) == 0 {
+		state.scheduler.Succeeded(0, 0)
+	}
 
-	// Update UI (must be done on main thread)
-	debugLog("refreshEntities: queuing UI update")
-	app.QueueUpdateDraw(func() {
-		updateListView(list, state, statusText, detailsText, app)
-	})
+	logFor("refresh").Debug("entities fetched", "entities", len(newEntities))
+	onUpdate()
 
 	// Fetch incidents asynchronously
 	if len(newEntities) > 0 {
-		debugLog(fmt.Sprintf("refreshEntities: launching async fetchIncidents for %d entities", len(newEntities)))
+		logFor("refresh").Debug("fetching incidents in the background", "entities", len(newEntities))
 		go func() {
-			fetchIncidents(config, &EntityList{Entities: newEntities})
+			entities := cloneEntities(newEntities)
+			started := time.Now()
+			incidentsOK := fetchIncidents(ctx, config, &EntityList{Entities: entities})
+			if ctx.Err() != nil {
+				logFor("refresh").Debug("abandoned while fetching incidents")
+				return
+			}
+			incidentsErr := ""
+			if !incidentsOK {
+				incidentsErr = "unavailable"
+			}
+			state.exporter.ObserveFetch("incidents", time.Since(started), incidentsErr)
 			// Sort so alerting entities are first
-			sort.SliceStable(newEntities, func(i, j int) bool {
-				if newEntities[i].HasAlert == newEntities[j].HasAlert {
-					return newEntities[i].Name < newEntities[j].Name
+			entities = sortByAlert(entities)
+			var changes []AlertTransition
+			if !state.publish(ctx, func(next *Snapshot) {
+				if incidentsOK {
+					// Only record transitions when alert state is known, otherwise a failed
+					// fetch would look like every alert resolving at once. This runs inside
+					// the publish so an abandoned refresh can't record transitions nobody sees.
+					changes = state.alerts.Observe(entities)
 				}
-				return newEntities[i].HasAlert && !newEntities[j].HasAlert
-			})
-			debugLog("refreshEntities: async fetchIncidents completed, queuing UI update")
-			app.QueueUpdateDraw(func() {
-				updateListView(list, state, statusText, detailsText, app)
-			})
+				next.Entities = applyView(entities, config.View)
+				next.Stale = false
+				next.RefreshedAt = time.Now()
+				next.IncidentsError = incidentsErr
+			}) {
+				logFor("refresh").Debug("abandoned before publishing incidents")
+				return
+			}
+			if !incidentsOK {
+				state.scheduler.Failed()
+			} else {
+				alerting := 0
+				for _, e := range entities {
+					if e.HasAlert {
+						alerting++
+					}
+				}
+				state.scheduler.Succeeded(alerting, len(changes))
+			}
+
+			// Everything below only reads the now-published entities
+			if incidentsOK {
+				state.notifier.Notify(changes)
+				state.webhooks.Forward(changes)
+				if config.ExportOnChange && len(changes) > 0 {
+					if path, _, err := exportEntities(entities, config.ExportFormat, config.ExportDir, ""); err != nil {
+						logFor("export").Warn("export on change failed", "err", err)
+					} else if state.eventLog != nil {
+						writeEvent(state.eventLog, "info", "export", map[string]interface{}{"path": path})
+					}
+				}
+			}
+			state.exporter.SetSnapshot(entities, incidentsOK)
+			if state.eventLog != nil {
+				logRefresh(state.eventLog, entities, incidentsOK, changes)
+			}
+			logFor("refresh").Debug("incidents published", "incidents_ok", incidentsOK, "transitions", len(changes))
+			onUpdate()
+
+			// Cache as soon as alert state is known, so a refresh abandoned during the detail
+			// lookups still leaves a fresh cache, then again with the details filled in
+			if incidentsOK {
+				saveRefreshCache(config, entities)
+			}
+			if entities = fetchDetails(ctx, state, config, entities, onUpdate); entities != nil && incidentsOK {
+				saveRefreshCache(config, entities)
+			}
 		}()
 	}
 }
 
-func updateListView(list *tview.List, state *AppState, statusText *tview.TextView, detailsText *tview.TextView, app *tview.Application) {
-	// Copy state under lock to avoid deadlocks when UI callbacks run
-	state.mu.Lock()
-	entitiesCopy := make([]*Entity, len(state.entities))
-	copy(entitiesCopy, state.entities)
-	refreshInProgress := state.refreshInProgress
-	errMsg := state.errMsg
-	lastRefresh := state.lastRefresh
-	selected := state.selectedIndex
-	state.mu.Unlock()
-
-	// Clear list and set status on UI thread
-	list.Clear()
-
-	// Update status
-	if refreshInProgress {
-		statusText.SetText("[yellow]⟳ Fetching from New Relic...")
-	} else if errMsg != "" {
-		statusText.SetText(fmt.Sprintf("[red]✗ Error: %s", errMsg))
-	} else if len(entitiesCopy) == 0 {
-		statusText.SetText("[dim]No entities found. Check API key and account ID.")
-		return
-	} else {
-		secondsAgo := int(time.Since(lastRefresh).Seconds())
-		statusText.SetText(fmt.Sprintf("[green]✓[white] Last updated: %d seconds ago", secondsAgo))
+func saveRefreshCache(config *Config, entities []*Entity) {
+	if err := saveCache(config, entities); err != nil {
+		logFor("cache").Warn("saving cache failed", "err", err)
 	}
+}
 
-	debugLog(fmt.Sprintf("updateListView: populating %d entities (chunked)", len(entitiesCopy)))
-
-	// Populate the list in background batches to avoid hogging the UI thread
-	batchSize := 25
-	total := len(entitiesCopy)
-	if total == 0 {
-		return
+// fetchDetails looks up tags and reporting state in batches on a bounded worker pool,
+// publishing each batch as it arrives. It returns entities with every batch applied, or nil
+// if the refresh was abandoned.
+func fetchDetails(ctx context.Context, state *AppState, config *Config, entities []*Entity, onUpdate func()) []*Entity {
+	guids := make([]string, 0, len(entities))
+	for _, e := range entities {
+		if e.GUID != "" {
+			guids = append(guids, e.GUID)
+		}
+	}
+	if config.Demo || len(guids) == 0 {
+		return entities
 	}
 
-	// Ensure colors are set early
-	list.SetMainTextColor(tcell.ColorWhite)
-	list.SetSecondaryTextColor(tcell.ColorGray)
-	list.SetShortcutColor(tcell.ColorYellow)
-
-	go func() {
-		for start := 0; start < total; start += batchSize {
-			end := start + batchSize
-			if end > total {
-				end = total
+	type batchResult struct {
+		details map[string]EntityDetails
+		err     error
+	}
+	jobs := make([]func(context.Context) batchResult, 0)
+	for _, batch := range batchGUIDs(guids, entityBatchSize) {
+		batch := batch
+		jobs = append(jobs, func(ctx context.Context) batchResult {
+			started := time.Now()
+			details, err := fetchEntityDetails(ctx, config, batch)
+			errType := ""
+			if err != nil {
+				errType = "unavailable"
 			}
-			batch := entitiesCopy[start:end]
-			s := start
-
-			app.QueueUpdateDraw(func() {
-				for j, entity := range batch {
-					i := s + j
-					status := "[green]OK"
-					if entity.HasAlert {
-						status = "[red]ALERT"
-					}
-					text := fmt.Sprintf("%-15s %s", entity.Name, status)
-					idx := i
-					list.AddItem(text, "", 0, func() {
-						list.SetCurrentItem(idx)
-						showDetails(idx, state, detailsText)
-					})
-				}
+			state.exporter.ObserveFetch("details", time.Since(started), errType)
+			return batchResult{details, err}
+		})
+	}
 
-				if end == total {
-					sel := selected
-					if sel < 0 && total > 0 {
-						sel = 0
-					}
-					if sel >= total {
-						sel = 0
-					}
-					if total > 0 {
-						list.SetCurrentItem(sel)
-					}
-				}
-			})
+	for res := range runPool(ctx, config.FetchConcurrency, jobs) {
+		if res.err != nil {
+			logFor("refresh").Warn("fetching entity details failed", "err", res.err)
+			continue
+		}
+		entities = withDetails(entities, res.details)
+		if !state.publish(ctx, func(next *Snapshot) {
+			next.Entities = applyView(entities, config.View)
+		}) {
+			return nil
+		}
+		onUpdate()
+	}
+	if ctx.Err() != nil {
+		logFor("refresh").Debug("abandoned while fetching entity details")
+		return nil
+	}
+	return entities
+}
 
-			time.Sleep(25 * time.Millisecond)
+// filterEntities keeps entities whose name contains filter (case-insensitive)
+func filterEntities(entities []*Entity, filter string) []*Entity {
+	if filter == "" {
+		return entities
+	}
+	q := strings.ToLower(filter)
+	filtered := make([]*Entity, 0, len(entities))
+	for _, e := range entities {
+		if strings.Contains(strings.ToLower(e.Name), q) {
+			filtered = append(filtered, e)
 		}
-	}()
+	}
+	return filtered
+}
+
+// applyView narrows entities to the configured view: all, alerting or flapping
+func applyView(entities []*Entity, view string) []*Entity {
+	if view == "all" || view == "" {
+		return entities
+	}
+	visible := make([]*Entity, 0, len(entities))
+	for _, e := range entities {
+		if (view == "alerting" && e.HasAlert) || (view == "flapping" && e.Flapping) {
+			visible = append(visible, e)
+		}
+	}
+	return visible
+}
+
+// updateListView syncs the list rows and status bar with state; must run on the UI thread
+func updateListView(list *EntityListView, state *AppState, statusBar *StatusBar) {
+	entities := state.Snapshot().Entities
+	statusBar.Refresh()
+	logFor("ui").Debug("syncing list", "entities", len(entities))
+	list.Sync(entities)
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// metricsRefreshInterval is how often metrics are re-queried for the selected host
+const metricsRefreshInterval = 30 * time.Second
+
+// HostMetrics holds the last hour of samples for a single host, oldest first
+type HostMetrics struct {
+	GUID      string
+	FetchedAt time.Time
+	CPU       []float64
+	Memory    []float64
+	Disk      []float64
+	Load      []float64
+	NetRx     []float64
+	NetTx     []float64
+	Error     string
+}
+
+// FetchHostMetrics queries SystemSample and NetworkSample for the given entity GUID in its account
+func FetchHostMetrics(ctx context.Context, config *Config, account, guid string) *HostMetrics {
+	m := &HostMetrics{GUID: guid, FetchedAt: time.Now()}
+
+	systemQuery := fmt.Sprintf("SELECT average(cpuPercent) AS cpu, average(memoryUsedPercent) AS memory, "+
+		"average(diskUsedPercent) AS disk, average(loadAverageOneMinute) AS load "+
+		"FROM SystemSample WHERE entityGuid = '%s' SINCE 1 hour ago TIMESERIES 5 minutes", nrqlQuote(guid))
+	rows, err := runNRQL(ctx, config, account, systemQuery)
+	if err != nil {
+		m.Error = err.Error()
+		logFor("metrics").Warn("SystemSample query failed", "guid", guid, "err", err)
+		return m
+	}
+	m.CPU = seriesValues(rows, "cpu")
+	m.Memory = seriesValues(rows, "memory")
+	m.Disk = seriesValues(rows, "disk")
+	m.Load = seriesValues(rows, "load")
+
+	// NetworkSample reports one row per interface, so sum across them
+	networkQuery := fmt.Sprintf("SELECT sum(receiveBytesPerSecond) AS rx, sum(transmitBytesPerSecond) AS tx "+
+		"FROM NetworkSample WHERE entityGuid = '%s' SINCE 1 hour ago TIMESERIES 5 minutes", nrqlQuote(guid))
+	rows, err = runNRQL(ctx, config, account, networkQuery)
+	if err != nil {
+		// Network data is optional; keep system metrics
+		logFor("metrics").Warn("NetworkSample query failed", "guid", guid, "err", err)
+		return m
+	}
+	m.NetRx = seriesValues(rows, "rx")
+	m.NetTx = seriesValues(rows, "tx")
+
+	logFor("metrics").Debug("fetched host metrics", "guid", guid, "buckets", len(m.CPU))
+	return m
+}
+
+// seriesValues extracts a numeric column from NRQL TIMESERIES rows; missing buckets become NaN
+func seriesValues(rows []map[string]interface{}, key string) []float64 {
+	values := make([]float64, 0, len(rows))
+	for _, row := range rows {
+		if v, ok := row[key].(float64); ok {
+			values = append(values, v)
+		} else {
+			values = append(values, math.NaN())
+		}
+	}
+	return values
+}
+
+// latestValue returns the most recent non-NaN sample, or NaN if there is none
+func latestValue(values []float64) float64 {
+	for i := len(values) - 1; i >= 0; i-- {
+		if !math.IsNaN(values[i]) {
+			return values[i]
+		}
+	}
+	return math.NaN()
+}
+
+var sparkRunes = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders values as a single line of block characters scaled to their range
+func sparkline(values []float64) string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		switch {
+		case math.IsNaN(v):
+			b.WriteRune(' ')
+		case hi == lo:
+			b.WriteRune(sparkRunes[0])
+		default:
+			idx := int((v - lo) / (hi - lo) * float64(len(sparkRunes)-1))
+			b.WriteRune(sparkRunes[idx])
+		}
+	}
+	return b.String()
+}
+
+// formatBytes renders a bytes-per-second rate using binary units
+func formatBytes(v float64) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	units := []string{"B/s", "KiB/s", "MiB/s", "GiB/s"}
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", v, units[i])
+}
+
+// formatPercent renders a percentage sample, or "-" when there is no data
+func formatPercent(v float64) string {
+	if math.IsNaN(v) {
+		return "-"
+	}
+	return fmt.Sprintf("%5.1f%%", v)
+}
+
+// writeMetrics appends the metrics block for a host to the details view
+func writeMetrics(w io.Writer, m *HostMetrics) {
+	if m == nil {
+		fmt.Fprintf(w, "[dim]Loading metrics...[-]\n")
+		return
+	}
+	if m.Error != "" {
+		fmt.Fprintf(w, "[dim]Metrics unavailable: %s[-]\n", tview.Escape(m.Error))
+		return
+	}
+	if len(m.CPU) == 0 {
+		fmt.Fprintf(w, "[dim]No SystemSample data in the last hour[-]\n")
+		return
+	}
+
+	fmt.Fprintf(w, "[::b]Metrics[::-] [dim](last hour, updated %s)[-]\n", m.FetchedAt.Format("15:04:05"))
+	fmt.Fprintf(w, "CPU    %s [teal]%s[-]\n", formatPercent(latestValue(m.CPU)), sparkline(m.CPU))
+	fmt.Fprintf(w, "Memory %s [teal]%s[-]\n", formatPercent(latestValue(m.Memory)), sparkline(m.Memory))
+	fmt.Fprintf(w, "Disk   %s [teal]%s[-]\n", formatPercent(latestValue(m.Disk)), sparkline(m.Disk))
+	load := latestValue(m.Load)
+	if math.IsNaN(load) {
+		fmt.Fprintf(w, "Load   %6s [teal]%s[-]\n", "-", sparkline(m.Load))
+	} else {
+		fmt.Fprintf(w, "Load   %6.2f [teal]%s[-]\n", load, sparkline(m.Load))
+	}
+	if len(m.NetRx) > 0 {
+		fmt.Fprintf(w, "Net rx %s [teal]%s[-]\n", formatBytes(latestValue(m.NetRx)), sparkline(m.NetRx))
+		fmt.Fprintf(w, "Net tx %s [teal]%s[-]\n", formatBytes(latestValue(m.NetTx)), sparkline(m.NetTx))
+	}
+}
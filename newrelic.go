@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -14,12 +15,18 @@ import (
 type Entity struct {
 	Name           string
 	GUID           string
+	AccountID      string // the account the entity search found it in
 	Type           string
 	HasAlert       bool
 	AlertType      string
 	AlertMessage   string
 	ConnectionInfo string
 	OS             string
+	Severity       string // "critical" or "warning" while alerting
+	Flapping       bool
+	FlapCount      int
+	NotReporting   bool              // the agent has stopped sending data
+	Tags           map[string]string // from actor.entities; replaced, never modified, once set
 }
 
 type EntityList struct {
@@ -28,7 +35,8 @@ type EntityList struct {
 }
 
 type NerdGraphQuery struct {
-	Query string `json:"query"`
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
 }
 
 type NerdGraphResponse struct {
@@ -51,21 +59,74 @@ type NerdGraphResponse struct {
 	} `json:"errors"`
 }
 
-func FetchEntities(config *Config) *EntityList {
+// FetchEntities lists infrastructure hosts in every configured account, searching the accounts
+// in parallel on the worker pool; cancelling ctx aborts the requests. Placeholder hosts are only
+// returned in demo mode: on failure the list is empty and Error is set.
+func FetchEntities(ctx context.Context, config *Config) *EntityList {
 	list := &EntityList{
 		Entities: make([]*Entity, 0),
 	}
 
-	if config.APIKey == "" || config.AccountID == "" {
-		list.Error = "API key or account ID not configured"
+	if config.Demo {
 		return addTestEntities(list)
 	}
 
+	accounts := accountIDs(config)
+	if config.APIKey == "" || len(accounts) == 0 {
+		list.Error = "API key or account ID not configured"
+		return list
+	}
+
+	type searchResult struct {
+		entities []*Entity
+		err      string
+	}
+	jobs := make([]func(context.Context) searchResult, 0, len(accounts))
+	for _, account := range accounts {
+		account := account
+		jobs = append(jobs, func(ctx context.Context) searchResult {
+			entities, err := searchHosts(ctx, config, account)
+			return searchResult{entities, err}
+		})
+	}
+	seen := make(map[string]bool)
+	for res := range runPool(ctx, config.FetchConcurrency, jobs) {
+		if res.err != "" {
+			// Every account must load, or the list would look like hosts had disappeared
+			if list.Error == "" {
+				list.Error = res.err
+			}
+			continue
+		}
+		for _, e := range res.entities {
+			if e.GUID != "" && seen[e.GUID] {
+				continue
+			}
+			seen[e.GUID] = true
+			list.Entities = append(list.Entities, e)
+		}
+	}
+	if list.Error != "" {
+		list.Entities = list.Entities[:0]
+		return list
+	}
+
+	// Register names before they are logged so host redaction can mask them
+	redactor.AddHostNames(list.Entities)
+	for _, entity := range list.Entities {
+		logFor("fetch").Debug("parsed entity", "name", entity.Name, "type", entity.Type)
+	}
+	return list
+}
+
+// searchHosts runs the entity search for one account, returning a message formatted like
+// EntityList.Error on failure
+func searchHosts(ctx context.Context, config *Config, account string) ([]*Entity, string) {
 	// NerdGraph query to fetch Host entities (without violations - fetch separately)
 	// Filtered to infrastructure hosts/servers
-	query := `{
+	query := `query($search: String!) {
 		actor {
-			entitySearch(query: "domain = 'INFRA' AND type = 'HOST'") {
+			entitySearch(query: $search) {
 				results {
 					entities {
 						guid
@@ -77,21 +138,20 @@ func FetchEntities(config *Config) *EntityList {
 		}
 	}`
 
-	payload := NerdGraphQuery{Query: query}
+	filter := fmt.Sprintf("domain = 'INFRA' AND type = 'HOST' AND accountId = %s", account)
+	payload := NerdGraphQuery{Query: query, Variables: map[string]interface{}{"search": filter}}
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
-		list.Error = "Error marshaling request: " + err.Error()
-		return addTestEntities(list)
+		return nil, "Error marshaling request: " + err.Error()
 	}
 
-	req, err := http.NewRequest("POST", "https://api.newrelic.com/graphql", bytes.NewReader(payloadBytes))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiHost(config)+"/graphql", bytes.NewReader(payloadBytes))
 	if err != nil {
-		list.Error = "Error creating request: " + err.Error()
-		debugLog("Error creating request: " + err.Error())
-		return addTestEntities(list)
+		logFor("fetch").Error("creating entity search request failed", "err", err)
+		return nil, "Error creating request: " + err.Error()
 	}
 
-	debugLog("Fetching entities from New Relic")
+	logFor("fetch").Debug("fetching entities from New Relic", "account", account)
 
 	req.Header.Set("API-Key", config.APIKey)
 	req.Header.Set("Content-Type", "application/json")
@@ -99,49 +159,46 @@ func FetchEntities(config *Config) *EntityList {
 	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		list.Error = "Error fetching from New Relic: " + err.Error()
-		debugLog("Fetch failed: " + err.Error())
-		return addTestEntities(list)
+		logFor("fetch").Warn("entity search failed", "err", err)
+		return nil, "Error fetching from New Relic: " + err.Error()
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		list.Error = "Error reading response: " + err.Error()
-		debugLog("Read failed: " + err.Error())
-		return addTestEntities(list)
+		logFor("fetch").Warn("reading entity search response failed", "err", err)
+		return nil, "Error reading response: " + err.Error()
 	}
 
-	debugLog(fmt.Sprintf("API Response Status: %d", resp.StatusCode))
+	logFor("fetch").Debug("entity search response", "status", resp.StatusCode)
 
 	// Try to parse response and check for errors
 	var nrResp map[string]interface{}
 	if err := json.Unmarshal(body, &nrResp); err != nil {
-		list.Error = "Error parsing response: " + err.Error()
-		debugLog("JSON parse failed: " + err.Error())
-		return addTestEntities(list)
+		logFor("fetch").Warn("parsing entity search response failed", "err", err)
+		return nil, "Error parsing response: " + err.Error()
 	}
 
 	// Check for GraphQL errors
 	if errors, ok := nrResp["errors"].([]interface{}); ok && len(errors) > 0 {
 		errorMsg := fmt.Sprintf("%v", errors[0])
-		list.Error = "New Relic API error: " + errorMsg
-		debugLog("GraphQL error: " + errorMsg)
-		return addTestEntities(list)
+		logFor("fetch").Warn("entity search returned an error", "err", errorMsg)
+		return nil, "New Relic API error: " + errorMsg
 	}
 
-	debugLog("Query successful, parsing entities...")
+	logFor("fetch").Debug("entity search succeeded, parsing entities")
 
 	// Parse entities from response
+	found := make([]*Entity, 0)
 	if data, ok := nrResp["data"].(map[string]interface{}); ok {
 		if actor, ok := data["actor"].(map[string]interface{}); ok {
 			if search, ok := actor["entitySearch"].(map[string]interface{}); ok {
 				if results, ok := search["results"].(map[string]interface{}); ok {
 					if entities, ok := results["entities"].([]interface{}); ok {
-						debugLog(fmt.Sprintf("Found %d entities", len(entities)))
+						logFor("fetch").Debug("found entities", "count", len(entities))
 						for _, entityData := range entities {
 							if entityMap, ok := entityData.(map[string]interface{}); ok {
-								entity := &Entity{}
+								entity := &Entity{AccountID: account}
 								
 								if name, ok := entityMap["name"].(string); ok {
 									entity.Name = name
@@ -154,8 +211,7 @@ func FetchEntities(config *Config) *EntityList {
 								}
 								
 								if entity.Name != "" {
-									debugLog(fmt.Sprintf("Parsed entity: %s (type: %s)", entity.Name, entity.Type))
-									list.Entities = append(list.Entities, entity)
+									found = append(found, entity)
 								}
 							}
 						}
@@ -165,75 +221,305 @@ func FetchEntities(config *Config) *EntityList {
 		}
 	}
 
-	return list
+	return found, ""
+}
+
+// apiHost is the New Relic API origin for the configured region; EU accounts are only served
+// from the EU hosts
+func apiHost(config *Config) string {
+	if config.Region == "eu" {
+		return "https://api.eu.newrelic.com"
+	}
+	return "https://api.newrelic.com"
 }
 
-func fetchIncidents(config *Config, list *EntityList) {
+// postNerdGraph sends a query to NerdGraph and returns the decoded "data" object
+func postNerdGraph(ctx context.Context, config *Config, query string, variables map[string]interface{}) (map[string]interface{}, error) {
+	payloadBytes, err := json.Marshal(NerdGraphQuery{Query: query, Variables: variables})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiHost(config)+"/graphql", bytes.NewReader(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("API-Key", config.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching from New Relic: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	var nrResp map[string]interface{}
+	if err := json.Unmarshal(body, &nrResp); err != nil {
+		return nil, fmt.Errorf("parsing response (status %d): %w", resp.StatusCode, err)
+	}
+	if errors, ok := nrResp["errors"].([]interface{}); ok && len(errors) > 0 {
+		if emap, ok := errors[0].(map[string]interface{}); ok {
+			if msg, ok := emap["message"].(string); ok {
+				return nil, fmt.Errorf("New Relic API error: %s", msg)
+			}
+		}
+		return nil, fmt.Errorf("New Relic API error: %v", errors[0])
+	}
+
+	data, _ := nrResp["data"].(map[string]interface{})
+	return data, nil
+}
+
+// nrqlQuote escapes a value for use inside a single-quoted NRQL string literal
+func nrqlQuote(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
+
+// runNRQL executes an NRQL query against account via NerdGraph, or the first configured account
+// when account is empty
+func runNRQL(ctx context.Context, config *Config, account, nrql string) ([]map[string]interface{}, error) {
+	accounts := accountIDs(config)
+	if config.APIKey == "" || len(accounts) == 0 {
+		return nil, fmt.Errorf("API key or account ID not configured")
+	}
+	if account == "" {
+		account = accounts[0]
+	}
+	accountID, err := strconv.Atoi(account)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account ID %q", account)
+	}
+
+	query := `query($accountId: Int!, $nrql: Nrql!) {
+		actor {
+			account(id: $accountId) {
+				nrql(query: $nrql) {
+					results
+				}
+			}
+		}
+	}`
+
+	logFor("nrql").Debug("running query", "nrql", nrql)
+	data, err := postNerdGraph(ctx, config, query, map[string]interface{}{
+		"accountId": accountID,
+		"nrql":      nrql,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]map[string]interface{}, 0)
+	if actor, ok := data["actor"].(map[string]interface{}); ok {
+		if account, ok := actor["account"].(map[string]interface{}); ok {
+			if nrqlResult, ok := account["nrql"].(map[string]interface{}); ok {
+				if results, ok := nrqlResult["results"].([]interface{}); ok {
+					for _, r := range results {
+						if row, ok := r.(map[string]interface{}); ok {
+							rows = append(rows, row)
+						}
+					}
+				}
+			}
+		}
+	}
+	return rows, nil
+}
+
+// EntityDetails is per-entity metadata looked up by GUID after the entity search
+type EntityDetails struct {
+	Reporting bool
+	Tags      map[string]string
+}
+
+// fetchEntityDetails looks up reporting state and tags for at most entityBatchSize GUIDs in a
+// single actor.entities call
+func fetchEntityDetails(ctx context.Context, config *Config, guids []string) (map[string]EntityDetails, error) {
+	query := `query($guids: [EntityGuid]!) {
+		actor {
+			entities(guids: $guids) {
+				guid
+				reporting
+				tags {
+					key
+					values
+				}
+			}
+		}
+	}`
+
+	data, err := postNerdGraph(ctx, config, query, map[string]interface{}{"guids": guids})
+	if err != nil {
+		return nil, err
+	}
+
+	details := make(map[string]EntityDetails, len(guids))
+	if actor, ok := data["actor"].(map[string]interface{}); ok {
+		if entities, ok := actor["entities"].([]interface{}); ok {
+			for _, ed := range entities {
+				emap, ok := ed.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				guid, _ := emap["guid"].(string)
+				if guid == "" {
+					continue
+				}
+				d := EntityDetails{Tags: make(map[string]string)}
+				d.Reporting, _ = emap["reporting"].(bool)
+				if tags, ok := emap["tags"].([]interface{}); ok {
+					for _, t := range tags {
+						tmap, ok := t.(map[string]interface{})
+						if !ok {
+							continue
+						}
+						key, _ := tmap["key"].(string)
+						values := make([]string, 0)
+						if vs, ok := tmap["values"].([]interface{}); ok {
+							for _, v := range vs {
+								values = append(values, fmt.Sprintf("%v", v))
+							}
+						}
+						if key != "" {
+							d.Tags[key] = strings.Join(values, ",")
+						}
+					}
+				}
+				details[guid] = d
+			}
+		}
+	}
+	logFor("fetch").Debug("fetched entity details", "found", len(details), "requested", len(guids))
+	return details, nil
+}
+
+// applyDetails copies looked-up metadata onto an entity that has not been published yet
+func applyDetails(e *Entity, d EntityDetails) {
+	e.NotReporting = !d.Reporting
+	e.Tags = d.Tags
+	if os := d.Tags["operatingSystem"]; os != "" {
+		e.OS = os
+	}
+}
+
+// withDetails returns entities with details applied, copying only the entities that change
+func withDetails(entities []*Entity, details map[string]EntityDetails) []*Entity {
+	updated := make([]*Entity, len(entities))
+	for i, e := range entities {
+		d, ok := details[e.GUID]
+		if !ok || e.GUID == "" {
+			updated[i] = e
+			continue
+		}
+		c := *e
+		applyDetails(&c, d)
+		updated[i] = &c
+	}
+	return updated
+}
+
+// carryDetails copies metadata from the previous snapshot onto freshly fetched entities so it
+// doesn't disappear until this refresh's lookups complete
+func carryDetails(entities, previous []*Entity) {
+	byGUID := make(map[string]*Entity, len(previous))
+	for _, e := range previous {
+		if e.GUID != "" {
+			byGUID[e.GUID] = e
+		}
+	}
+	for _, e := range entities {
+		if prev, ok := byGUID[e.GUID]; ok && prev.Tags != nil {
+			e.NotReporting = prev.NotReporting
+			e.Tags = prev.Tags
+			e.OS = prev.OS
+		}
+	}
+}
+
+// fetchIncidents marks alerting entities in list; it returns false if alert state could not be
+// determined, including when ctx is cancelled
+func fetchIncidents(ctx context.Context, config *Config, list *EntityList) (ok bool) {
 	defer func() {
 		if r := recover(); r != nil {
-			debugLog(fmt.Sprintf("fetchIncidents panic: %v", r))
+			logFor("fetch").Error("fetchIncidents panic", "panic", r)
+			ok = false
 		}
 	}()
 
-	debugLog("fetchIncidents: starting")
+	if config.Demo {
+		// Demo entities carry their own alert state
+		return true
+	}
+
+	logFor("fetch").Debug("fetching incidents")
 	// Use REST alerts/violations API which is proven to work
-	done := make(chan struct{})
-	go func() {
-		defer close(done)
-		fetchViolationsREST(config, list)
-	}()
-	select {
-	case <-done:
-		debugLog("fetchIncidents: REST completed")
-	case <-time.After(15 * time.Second):
-		debugLog("fetchIncidents: REST timed out")
+	if err := fetchViolationsREST(ctx, config, list); err != nil {
+		logFor("fetch").Warn("fetching incidents failed", "err", err)
+		return false
 	}
-	debugLog("fetchIncidents: completed")
+	logFor("fetch").Debug("fetched incidents")
+	return true
 }
 
-// fetchViolationsREST calls New Relic classic Alerts Violations REST API as a fallback
-func fetchViolationsREST(config *Config, list *EntityList) {
+// fetchViolationsREST calls New Relic classic Alerts Violations REST API as a fallback. Entities
+// in list are only modified once the whole response has arrived and ctx is still live.
+func fetchViolationsREST(ctx context.Context, config *Config, list *EntityList) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			debugLog(fmt.Sprintf("fetchViolationsREST panic: %v", r))
+			logFor("fetch").Error("fetchViolationsREST panic", "panic", r)
+			err = fmt.Errorf("panic: %v", r)
 		}
 	}()
 
-	url := "https://api.newrelic.com/v2/alerts_violations.json?only_open=true"
-	req, err := http.NewRequest("GET", url, nil)
+	// Bound the request so a stalled API cannot hold up the refresh indefinitely
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	url := apiHost(config) + "/v2/alerts_violations.json?only_open=true"
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		debugLog("fetchViolationsREST: request create error: " + err.Error())
-		return
+		logFor("fetch").Error("creating violations request failed", "err", err)
+		return err
 	}
 	// v2 REST API expects X-Api-Key header
 	req.Header.Set("X-Api-Key", config.APIKey)
 	req.Header.Set("Content-Type", "application/json")
 
-	// Use context timeout to ensure this cannot hang indefinitely
-	ctx, cancel := context.WithTimeout(context.Background(), 12*time.Second)
-	defer cancel()
-	req = req.WithContext(ctx)
-
 	client := &http.Client{Timeout: 0}
 	resp, err := client.Do(req)
 	if err != nil {
-		debugLog("fetchViolationsREST: http error: " + err.Error())
-		return
+		logFor("fetch").Debug("violations request failed", "err", err)
+		return err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		debugLog("fetchViolationsREST: read error: " + err.Error())
-		return
+		logFor("fetch").Debug("reading violations response failed", "err", err)
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		logFor("fetch").Debug("unexpected violations response", "status", resp.StatusCode)
+		return fmt.Errorf("violations API returned status %d", resp.StatusCode)
 	}
 
-	debugLog("Violations REST response received")
+	logFor("fetch").Debug("violations response received")
 
 	var respObj map[string]interface{}
 	if err := json.Unmarshal(body, &respObj); err != nil {
-		debugLog("fetchViolationsREST: json unmarshal error: " + err.Error())
-		return
+		logFor("fetch").Debug("parsing violations response failed", "err", err)
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
 	violations, _ := respObj["violations"].([]interface{})
@@ -242,6 +528,7 @@ func fetchViolationsREST(config *Config, list *EntityList) {
 		if vmap, ok := v.(map[string]interface{}); ok {
 			title := ""
 			details := ""
+			severity := ""
 			targetNames := make([]string, 0)
 
 			if t, ok := vmap["condition_name"].(string); ok {
@@ -250,6 +537,9 @@ func fetchViolationsREST(config *Config, list *EntityList) {
 			if d, ok := vmap["details"].(string); ok {
 				details = d
 			}
+			if p, ok := vmap["priority"].(string); ok {
+				severity = strings.ToLower(p)
+			}
 
 			// Try to extract target name(s)
 			if targets, ok := vmap["targets"].([]interface{}); ok {
@@ -287,14 +577,19 @@ func fetchViolationsREST(config *Config, list *EntityList) {
 							entity.AlertType = title
 						}
 						entity.AlertMessage = details
-						debugLog(fmt.Sprintf("Matched REST violation to %s via name '%s'", entity.Name, tn))
+						// Keep the most severe priority when several violations match
+						if entity.Severity != "critical" && severity != "" {
+							entity.Severity = severity
+						}
+						logFor("fetch").Debug("matched violation", "entity", entity.Name, "target", tn)
 						matched++
 					}
 				}
 			}
 		}
 	}
-	debugLog(fmt.Sprintf("Matched %d REST violations to entities", matched))
+	logFor("fetch").Debug("matched violations to entities", "matched", matched)
+	return nil
 }
 
 func addTestEntities(list *EntityList) *EntityList {
@@ -313,6 +608,7 @@ func addTestEntities(list *EntityList) *EntityList {
 			HasAlert:       true,
 			AlertType:      "CPU High",
 			AlertMessage:   "CPU > 85%",
+			Severity:       "critical",
 			OS:             "Linux",
 			ConnectionInfo: "10.0.1.2",
 		},
@@ -329,6 +625,7 @@ func addTestEntities(list *EntityList) *EntityList {
 			HasAlert:       true,
 			AlertType:      "Memory",
 			AlertMessage:   "Memory > 90%",
+			Severity:       "warning",
 			OS:             "Linux",
 			ConnectionInfo: "10.0.1.4",
 		},
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Notifier fires local notifications when entities start alerting
+type Notifier struct {
+	methods    []string
+	command    string
+	severities map[string]bool // empty means every severity
+	cooldown   time.Duration
+	wake       func() // triggers a redraw to flush pending; nil when there is no terminal UI
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+	pending  []string // bell and OSC sequences waiting for the UI goroutine
+}
+
+// NewNotifier builds a notifier from the notify_* config keys
+func NewNotifier(config *Config) *Notifier {
+	n := &Notifier{
+		methods:    config.NotifyMethods,
+		command:    config.NotifyCommand,
+		severities: make(map[string]bool),
+		cooldown:   time.Duration(config.NotifyCooldown) * time.Second,
+		lastSent:   make(map[string]time.Time),
+	}
+	for _, sev := range config.NotifySeverities {
+		n.severities[sev] = true
+	}
+	return n
+}
+
+// AttachScreen sends bell and OSC notifications through app's screen. They are written on the
+// UI goroutine right after a draw, so they never interleave with tcell's own output. Without a
+// screen attached (headless mode) those methods are skipped.
+func (n *Notifier) AttachScreen(app *tview.Application) {
+	app.SetAfterDrawFunc(func(screen tcell.Screen) {
+		n.mu.Lock()
+		pending := n.pending
+		n.pending = nil
+		n.mu.Unlock()
+		for _, seq := range pending {
+			if seq == "\a" {
+				screen.Beep()
+				continue
+			}
+			if tty, ok := screen.Tty(); ok {
+				io.WriteString(tty, seq)
+			}
+		}
+	})
+	n.wake = func() {
+		app.QueueUpdateDraw(func() {})
+	}
+}
+
+// queueTerminal holds seq for the next draw; it reports whether there is a screen to send it to
+func (n *Notifier) queueTerminal(seq string) bool {
+	if n.wake == nil {
+		return false
+	}
+	n.mu.Lock()
+	n.pending = append(n.pending, seq)
+	n.mu.Unlock()
+	return true
+}
+
+// stripControl removes C0 and C1 control characters, including ESC and BEL, so names and
+// conditions from New Relic can't end an OSC sequence early or inject their own
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || (r >= 0x7f && r <= 0x9f) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// severityLabel returns the entity's severity, or "unknown" when the alert source didn't say
+func severityLabel(e *Entity) string {
+	if e.Severity == "" {
+		return "unknown"
+	}
+	return e.Severity
+}
+
+// Notify sends one notification covering every newly alerting entity in transitions that has
+// an opted-in severity and is not within its cooldown
+func (n *Notifier) Notify(transitions []AlertTransition) {
+	if len(n.methods) == 0 {
+		return
+	}
+
+	n.mu.Lock()
+	now := time.Now()
+	fresh := make([]*Entity, 0)
+	for _, t := range transitions {
+		if !t.Alerting {
+			continue
+		}
+		if len(n.severities) > 0 && !n.severities[severityLabel(t.Entity)] {
+			continue
+		}
+		key := entityKey(t.Entity)
+		if last, ok := n.lastSent[key]; ok && now.Sub(last) < n.cooldown {
+			logFor("notify").Debug("cooldown active", "entity", t.Entity.Name)
+			continue
+		}
+		n.lastSent[key] = now
+		fresh = append(fresh, t.Entity)
+	}
+	n.mu.Unlock()
+
+	if len(fresh) == 0 {
+		return
+	}
+
+	// Batch everything from one refresh into a single notification to avoid storms
+	title := fmt.Sprintf("Osiris: %s alerting", fresh[0].Name)
+	body := fmt.Sprintf("[%s] %s", severityLabel(fresh[0]), fresh[0].AlertType)
+	if len(fresh) > 1 {
+		names := make([]string, 0, len(fresh))
+		for _, e := range fresh {
+			names = append(names, e.Name)
+		}
+		title = fmt.Sprintf("Osiris: %d new alerts", len(fresh))
+		body = strings.Join(names, ", ")
+	}
+	logFor("notify").Info("notifying", "title", title, "body", body)
+
+	queued := false
+	for _, method := range n.methods {
+		switch method {
+		case "bell":
+			queued = n.queueTerminal("\a") || queued
+		case "osc9":
+			queued = n.queueTerminal(fmt.Sprintf("\x1b]9;%s: %s\a", stripControl(title), stripControl(body))) || queued
+		case "osc777":
+			queued = n.queueTerminal(fmt.Sprintf("\x1b]777;notify;%s;%s\a", stripControl(title), stripControl(body))) || queued
+		case "desktop":
+			go runNotifyCommand(desktopNotifyCommand(title, body), nil)
+		case "command":
+			if n.command == "" {
+				continue
+			}
+			env := []string{
+				"OSIRIS_TITLE=" + title,
+				"OSIRIS_MESSAGE=" + body,
+				"OSIRIS_ENTITY=" + fresh[0].Name,
+				"OSIRIS_GUID=" + fresh[0].GUID,
+				"OSIRIS_SEVERITY=" + severityLabel(fresh[0]),
+				"OSIRIS_CONDITION=" + fresh[0].AlertType,
+				fmt.Sprintf("OSIRIS_COUNT=%d", len(fresh)),
+			}
+			go runNotifyCommand(shellCommand(n.command), env)
+		default:
+			logFor("notify").Warn("unknown notify method", "method", method)
+		}
+	}
+	if queued {
+		n.wake()
+	}
+}
+
+// desktopNotifyCommand picks the platform's desktop notification tool
+func desktopNotifyCommand(title, body string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", body, title)
+		return exec.Command("osascript", "-e", script)
+	case "windows":
+		return nil
+	}
+	return exec.Command("notify-send", "--app-name=osiris", title, body)
+}
+
+// shellCommand runs a user-supplied command line through the platform shell
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+func runNotifyCommand(cmd *exec.Cmd, env []string) {
+	if cmd == nil {
+		return
+	}
+	cmd.Env = append(os.Environ(), env...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		logFor("notify").Warn("notification command failed", "cmd", cmd.Path, "err", err, "output", strings.TrimSpace(string(out)))
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// nrqlHistoryLimit caps the number of queries kept in ~/.osiris/nrql_history
+const nrqlHistoryLimit = 200
+
+// NRQLConsole is a panel for running ad-hoc NRQL queries against the configured account
+type NRQLConsole struct {
+	root    *tview.Flex
+	input   *tview.InputField
+	results *tview.Pages
+	table   *tview.Table
+	chart   *tview.TextView
+	status  *tview.TextView
+
+	config  *Config
+	app     *tview.Application
+	onClose func()
+	ctx     context.Context    // the app's context, cancelled on quit
+	cancel  context.CancelFunc // cancels the running query
+
+	entity     *Entity
+	history    []string
+	historyPos int
+	rows       []map[string]interface{}
+	showChart  bool
+}
+
+// NewNRQLConsole builds the console; onClose is called on the UI thread when the user leaves it.
+// Queries run under ctx and are cancelled when the console closes.
+func NewNRQLConsole(ctx context.Context, config *Config, app *tview.Application, onClose func()) *NRQLConsole {
+	c := &NRQLConsole{
+		ctx:     ctx,
+		config:  config,
+		app:     app,
+		onClose: onClose,
+		history: loadNRQLHistory(),
+	}
+
+	c.input = tview.NewInputField().SetLabel("NRQL> ").SetFieldBackgroundColor(tcell.ColorBlack)
+	c.table = tview.NewTable().SetBorders(false).SetFixed(1, 0).SetSelectable(true, false)
+	c.chart = tview.NewTextView().SetDynamicColors(true)
+	c.status = tview.NewTextView().SetDynamicColors(true)
+
+	c.results = tview.NewPages().
+		AddPage("table", c.table, true, true).
+		AddPage("chart", c.chart, true, false)
+	c.results.SetBorder(true).SetTitle(" Results ")
+
+	help := tview.NewTextView().SetDynamicColors(true).
+		SetText("[dim]Enter[-] run | [dim]↑↓[-] history | [dim]Ctrl-T[-] table/chart | [dim]Tab[-] results | [dim]Esc[-] close | vars: [yellow]{{name}} {{guid}}[-]")
+
+	c.root = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(c.input, 1, 0, true).
+		AddItem(c.status, 1, 0, false).
+		AddItem(c.results, 0, 1, false).
+		AddItem(help, 1, 0, false)
+	c.root.SetBorder(true).SetTitle(" NRQL Console ")
+
+	c.input.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			c.run(c.input.GetText())
+		case tcell.KeyEsc:
+			c.Close()
+		case tcell.KeyTab:
+			c.app.SetFocus(c.table)
+		}
+	})
+
+	c.input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp:
+			c.stepHistory(-1)
+			return nil
+		case tcell.KeyDown:
+			c.stepHistory(1)
+			return nil
+		case tcell.KeyCtrlT:
+			c.toggleView()
+			return nil
+		}
+		return event
+	})
+
+	c.table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyTab, tcell.KeyEsc:
+			c.app.SetFocus(c.input)
+			return nil
+		case tcell.KeyCtrlT:
+			c.toggleView()
+			return nil
+		}
+		return event
+	})
+
+	return c
+}
+
+// Open resets the console for the given entity, which supplies the template variables
+func (c *NRQLConsole) Open(entity *Entity) {
+	c.entity = entity
+	c.historyPos = len(c.history)
+	if entity != nil {
+		c.status.SetText(fmt.Sprintf("[dim]Selected: %s (%s)", tview.Escape(entity.Name), tview.Escape(entity.GUID)))
+	} else {
+		c.status.SetText("[dim]No entity selected")
+	}
+	c.app.SetFocus(c.input)
+}
+
+// Close cancels any running query and returns to the main view
+func (c *NRQLConsole) Close() {
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+	c.onClose()
+}
+
+// run expands template variables and executes the query off the UI thread
+func (c *NRQLConsole) run(raw string) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return
+	}
+	c.addHistory(raw)
+
+	nrql := expandNRQLTemplate(raw, c.entity)
+	// Queries run in the selected host's account, since its data lives there
+	account := ""
+	if c.entity != nil {
+		account = c.entity.AccountID
+	}
+	c.status.SetText("[yellow]⟳ Running query...")
+	// A new query replaces one still running
+	if c.cancel != nil {
+		c.cancel()
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.cancel = cancel
+	go func() {
+		started := time.Now()
+		rows, err := runNRQL(ctx, c.config, account, nrql)
+		elapsed := time.Since(started)
+		c.app.QueueUpdateDraw(func() {
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				c.status.SetText(fmt.Sprintf("[red]✗ %s", tview.Escape(err.Error())))
+				return
+			}
+			c.rows = rows
+			c.showChart = isTimeseries(rows)
+			c.render()
+			c.status.SetText(fmt.Sprintf("[green]✓[white] %d rows in %dms", len(rows), elapsed.Milliseconds()))
+		})
+	}()
+}
+
+func (c *NRQLConsole) toggleView() {
+	c.showChart = !c.showChart
+	c.render()
+}
+
+func (c *NRQLConsole) render() {
+	renderNRQLTable(c.table, c.rows)
+	c.chart.SetText(renderNRQLChart(c.rows))
+	if c.showChart {
+		c.results.SwitchToPage("chart")
+	} else {
+		c.results.SwitchToPage("table")
+	}
+}
+
+// stepHistory moves through previous queries; stepping past the newest clears the input
+func (c *NRQLConsole) stepHistory(delta int) {
+	pos := c.historyPos + delta
+	if pos < 0 || pos > len(c.history) {
+		return
+	}
+	c.historyPos = pos
+	if pos == len(c.history) {
+		c.input.SetText("")
+	} else {
+		c.input.SetText(c.history[pos])
+	}
+}
+
+func (c *NRQLConsole) addHistory(q string) {
+	if len(c.history) == 0 || c.history[len(c.history)-1] != q {
+		c.history = append(c.history, q)
+	}
+	if len(c.history) > nrqlHistoryLimit {
+		c.history = c.history[len(c.history)-nrqlHistoryLimit:]
+	}
+	c.historyPos = len(c.history)
+	saveNRQLHistory(c.history)
+}
+
+// expandNRQLTemplate substitutes {{name}} and {{guid}} with the selected entity's values, escaped
+// for use inside a quoted NRQL string
+func expandNRQLTemplate(q string, entity *Entity) string {
+	if entity == nil {
+		return q
+	}
+	return strings.NewReplacer("{{name}}", nrqlQuote(entity.Name), "{{guid}}", nrqlQuote(entity.GUID)).Replace(q)
+}
+
+func loadNRQLHistory() []string {
+	history := make([]string, 0)
+	f, err := os.Open(getOsirisPath("nrql_history"))
+	if err != nil {
+		return history
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			history = append(history, line)
+		}
+	}
+	return history
+}
+
+func saveNRQLHistory(history []string) {
+	path := getOsirisPath("nrql_history")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		logFor("nrql").Warn("saving query history failed", "err", err)
+		return
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(history, "\n")+"\n"), 0600); err != nil {
+		logFor("nrql").Warn("saving query history failed", "err", err)
+	}
+}
+
+// isTimeseries reports whether rows came from a TIMESERIES query
+func isTimeseries(rows []map[string]interface{}) bool {
+	if len(rows) == 0 {
+		return false
+	}
+	_, ok := rows[0]["beginTimeSeconds"]
+	return ok
+}
+
+// nrqlColumns returns the union of keys across rows, sorted with time and facet columns first
+func nrqlColumns(rows []map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	columns := make([]string, 0)
+	for _, row := range rows {
+		for k := range row {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+	rank := func(k string) int {
+		switch k {
+		case "beginTimeSeconds":
+			return 0
+		case "endTimeSeconds":
+			return 1
+		case "facet":
+			return 2
+		}
+		return 3
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		if rank(columns[i]) != rank(columns[j]) {
+			return rank(columns[i]) < rank(columns[j])
+		}
+		return columns[i] < columns[j]
+	})
+	return columns
+}
+
+func formatNRQLValue(column string, v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		return val
+	case float64:
+		if column == "beginTimeSeconds" || column == "endTimeSeconds" || column == "timestamp" {
+			secs := val
+			if column == "timestamp" {
+				secs = val / 1000
+			}
+			return time.Unix(int64(secs), 0).Format("01-02 15:04:05")
+		}
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+		return string(b)
+	}
+}
+
+func renderNRQLTable(table *tview.Table, rows []map[string]interface{}) {
+	table.Clear()
+	columns := nrqlColumns(rows)
+	for col, name := range columns {
+		table.SetCell(0, col, tview.NewTableCell(tview.Escape(name)).
+			SetTextColor(tcell.ColorYellow).
+			SetAttributes(tcell.AttrBold).
+			SetSelectable(false))
+	}
+	for r, row := range rows {
+		for col, name := range columns {
+			table.SetCell(r+1, col, tview.NewTableCell(tview.Escape(formatNRQLValue(name, row[name]))).SetMaxWidth(60))
+		}
+	}
+	table.ScrollToBeginning()
+}
+
+// renderNRQLChart draws one sparkline per numeric series (split by facet) for TIMESERIES results
+func renderNRQLChart(rows []map[string]interface{}) string {
+	if !isTimeseries(rows) {
+		return "[dim]Chart view needs a TIMESERIES query"
+	}
+
+	type series struct {
+		label  string
+		values []float64
+	}
+	order := make([]string, 0)
+	bySeries := make(map[string]*series)
+	for _, row := range rows {
+		facet := ""
+		if f, ok := row["facet"]; ok {
+			facet = formatNRQLValue("facet", f)
+		}
+		for _, col := range nrqlColumns([]map[string]interface{}{row}) {
+			if col == "beginTimeSeconds" || col == "endTimeSeconds" || col == "facet" {
+				continue
+			}
+			v, ok := row[col].(float64)
+			if !ok {
+				if row[col] != nil {
+					// Non-numeric column, nothing to chart
+					continue
+				}
+				v = math.NaN()
+			}
+			label := col
+			if facet != "" {
+				label = fmt.Sprintf("%s [%s]", col, facet)
+			}
+			s, ok := bySeries[label]
+			if !ok {
+				s = &series{label: label}
+				bySeries[label] = s
+				order = append(order, label)
+			}
+			s.values = append(s.values, v)
+		}
+	}
+	if len(order) == 0 {
+		return "[dim]No numeric series in results"
+	}
+
+	var b strings.Builder
+	first := formatNRQLValue("beginTimeSeconds", rows[0]["beginTimeSeconds"])
+	last := formatNRQLValue("endTimeSeconds", rows[len(rows)-1]["endTimeSeconds"])
+	fmt.Fprintf(&b, "[dim]%s → %s[-]\n\n", first, last)
+	for _, label := range order {
+		s := bySeries[label]
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, v := range s.values {
+			if !math.IsNaN(v) {
+				lo = math.Min(lo, v)
+				hi = math.Max(hi, v)
+			}
+		}
+		fmt.Fprintf(&b, "[::b]%s[::-]\n", tview.Escape(label))
+		if math.IsInf(lo, 1) {
+			fmt.Fprintf(&b, "  [dim]no data[-]\n\n")
+			continue
+		}
+		fmt.Fprintf(&b, "  [teal]%s[-]\n", sparkline(s.values))
+		fmt.Fprintf(&b, "  [dim]latest[-] %g  [dim]min[-] %g  [dim]max[-] %g\n\n", latestValue(s.values), lo, hi)
+	}
+	return b.String()
+}
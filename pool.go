@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// entityBatchSize is the most GUIDs NerdGraph accepts in one actor.entities call
+const entityBatchSize = 25
+
+// runPool runs jobs on at most workers goroutines and streams each result on the returned
+// channel as soon as it is ready. The channel is closed once every started job has finished;
+// jobs not yet started when ctx is cancelled are skipped.
+func runPool[T any](ctx context.Context, workers int, jobs []func(context.Context) T) <-chan T {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make(chan T, len(jobs))
+	queue := make(chan func(context.Context) T)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(jobs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				results <- job(ctx)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(results)
+	feed:
+		for _, job := range jobs {
+			// select picks at random when both are ready, so check for cancellation first
+			if ctx.Err() != nil {
+				break
+			}
+			select {
+			case queue <- job:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(queue)
+		wg.Wait()
+	}()
+	return results
+}
+
+// batchGUIDs splits guids into chunks of at most size
+func batchGUIDs(guids []string, size int) [][]string {
+	batches := make([][]string, 0, (len(guids)+size-1)/size)
+	for len(guids) > size {
+		batches = append(batches, guids[:size])
+		guids = guids[size:]
+	}
+	if len(guids) > 0 {
+		batches = append(batches, guids)
+	}
+	return batches
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatchGUIDs(t *testing.T) {
+	guids := func(n int) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = fmt.Sprintf("g%d", i)
+		}
+		return out
+	}
+	tests := []struct {
+		name  string
+		n     int
+		size  int
+		sizes []int
+	}{
+		{"empty", 0, 25, nil},
+		{"one partial batch", 3, 25, []int{3}},
+		{"exactly one batch", 25, 25, []int{25}},
+		{"one over", 26, 25, []int{25, 1}},
+		{"several", 60, 25, []int{25, 25, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := guids(tt.n)
+			batches := batchGUIDs(in, tt.size)
+			var sizes []int
+			var joined []string
+			for _, b := range batches {
+				sizes = append(sizes, len(b))
+				joined = append(joined, b...)
+			}
+			if fmt.Sprint(sizes) != fmt.Sprint(tt.sizes) {
+				t.Errorf("batch sizes = %v, want %v", sizes, tt.sizes)
+			}
+			if fmt.Sprint(joined) != fmt.Sprint(in) {
+				t.Errorf("batches don't cover the input in order: %v", joined)
+			}
+		})
+	}
+}
+
+func TestRunPool(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+		jobs    int
+	}{
+		{"no jobs", 4, 0},
+		{"fewer jobs than workers", 4, 2},
+		{"more jobs than workers", 3, 20},
+		{"zero workers still runs", 0, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var running, peak int32
+			jobs := make([]func(context.Context) int, tt.jobs)
+			for i := range jobs {
+				i := i
+				jobs[i] = func(ctx context.Context) int {
+					n := atomic.AddInt32(&running, 1)
+					for {
+						p := atomic.LoadInt32(&peak)
+						if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+							break
+						}
+					}
+					time.Sleep(time.Millisecond)
+					atomic.AddInt32(&running, -1)
+					return i
+				}
+			}
+
+			var got []int
+			for res := range runPool(context.Background(), tt.workers, jobs) {
+				got = append(got, res)
+			}
+			sort.Ints(got)
+			if len(got) != tt.jobs {
+				t.Fatalf("got %d results, want %d", len(got), tt.jobs)
+			}
+			for i, v := range got {
+				if v != i {
+					t.Fatalf("results = %v, want each job once", got)
+				}
+			}
+			limit := tt.workers
+			if limit < 1 {
+				limit = 1
+			}
+			if int(peak) > limit {
+				t.Errorf("%d jobs ran at once, want at most %d", peak, limit)
+			}
+		})
+	}
+}
+
+func TestRunPoolStreamsResults(t *testing.T) {
+	release := make(chan struct{})
+	jobs := []func(context.Context) string{
+		func(context.Context) string { return "fast" },
+		func(context.Context) string { <-release; return "slow" },
+	}
+	results := runPool(context.Background(), 2, jobs)
+	select {
+	case res := <-results:
+		if res != "fast" {
+			t.Errorf("first result = %q, want fast", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the fast result waited for the slow job")
+	}
+	close(release)
+	if res := <-results; res != "slow" {
+		t.Errorf("second result = %q, want slow", res)
+	}
+	if _, open := <-results; open {
+		t.Error("results channel not closed after the last job")
+	}
+}
+
+func TestRunPoolCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var started int32
+	jobs := make([]func(context.Context) error, 10)
+	for i := range jobs {
+		jobs[i] = func(ctx context.Context) error {
+			if atomic.AddInt32(&started, 1) == 1 {
+				cancel()
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}
+	}
+
+	n := 0
+	for err := range runPool(ctx, 1, jobs) {
+		if err == nil {
+			t.Error("job did not see the cancellation")
+		}
+		n++
+	}
+	// The feeder may already be blocked handing over the next job when cancel lands
+	if n > 2 {
+		t.Errorf("%d jobs ran after cancel, want the queue to stop", n)
+	}
+}
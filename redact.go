@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// secretPatterns match credentials that must never reach a log file or export
+var secretPatterns = []*regexp.Regexp{
+	// New Relic user, ingest and insights keys
+	regexp.MustCompile(`\bNR(?:AK|II|IQ|IK)-[A-Za-z0-9]{20,}`),
+	// License keys: 40 hex characters, or 36 plus the NRAL suffix
+	regexp.MustCompile(`\b[0-9a-fA-F]{36}NRAL\b|\b[0-9a-fA-F]{40}\b`),
+	regexp.MustCompile(`(?i)\b((?:bearer|basic)\s+)[A-Za-z0-9._~+/=-]{8,}`),
+	// key=value and "key": "value" forms of common secret names, keeping the key
+	regexp.MustCompile(`(?i)((?:api[_-]?key|x-api-key|license[_-]?key|token|secret|password|passwd)["']?\s*[:=]\s*["']?)[^\s"'&,;]+`),
+}
+
+var ipPattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|\b(?:[0-9a-fA-F]{1,4}:){3,7}[0-9a-fA-F]{1,4}\b`)
+
+// Redactor masks secrets, and optionally host names and IP addresses, in text
+type Redactor struct {
+	mu       sync.RWMutex
+	literals []string // exact secrets such as the configured API key
+	patterns []*regexp.Regexp
+	hosts    bool
+	names    *strings.Replacer
+	known    map[string]bool
+}
+
+// redactor is applied to every log line, headless event and export
+var redactor = &Redactor{}
+
+// configureRedaction loads the API key, redact_hosts and redact_pattern settings
+func configureRedaction(config *Config) {
+	r := &Redactor{hosts: config.RedactHosts}
+	if len(config.APIKey) >= 8 {
+		r.literals = append(r.literals, config.APIKey)
+	}
+	r.patterns = append(r.patterns, secretPatterns...)
+	for _, p := range config.RedactPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			logFor("config").Warn("ignoring redact_pattern", "pattern", p, "err", err)
+			continue
+		}
+		r.patterns = append(r.patterns, re)
+	}
+
+	redactor.mu.Lock()
+	redactor.literals, redactor.patterns, redactor.hosts = r.literals, r.patterns, r.hosts
+	redactor.mu.Unlock()
+}
+
+// AddHostNames adds entity names to those masked when host redaction is on. Names are never
+// forgotten, so hosts that have since disappeared stay masked too.
+func (r *Redactor) AddHostNames(entities []*Entity) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.hosts {
+		return
+	}
+	if r.known == nil {
+		r.known = make(map[string]bool)
+	}
+	added := false
+	for _, e := range entities {
+		if len(e.Name) >= 3 && !r.known[e.Name] {
+			r.known[e.Name] = true
+			added = true
+		}
+	}
+	if !added {
+		return
+	}
+
+	// Longest first so "web-1" doesn't shadow "web-10"
+	names := make([]string, 0, len(r.known))
+	for name := range r.known {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	pairs := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		pairs = append(pairs, name, hostToken(name))
+	}
+	r.names = strings.NewReplacer(pairs...)
+}
+
+// hostToken is a stable stand-in for a host name so redacted logs can still be correlated
+func hostToken(name string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(name)))
+	return "host-" + hex.EncodeToString(sum[:3])
+}
+
+// Redact returns s with secrets masked, and host names and IPs too when enabled
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, lit := range r.literals {
+		s = strings.ReplaceAll(s, lit, "[REDACTED]")
+	}
+	for _, re := range r.patterns {
+		if re.NumSubexp() > 0 {
+			// Keep the leading group (e.g. "api_key=") and mask the rest of the match
+			s = re.ReplaceAllString(s, "${1}[REDACTED]")
+		} else {
+			s = re.ReplaceAllString(s, "[REDACTED]")
+		}
+	}
+	if r.hosts {
+		if r.names != nil {
+			s = r.names.Replace(s)
+		}
+		s = ipPattern.ReplaceAllString(s, "[IP]")
+	}
+	return s
+}
+
+// redactWriter redacts everything written through it; each Write is redacted on its own, so
+// callers should write whole lines or records
+type redactWriter struct {
+	w io.Writer
+}
+
+func (rw redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, redactor.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	base := &Redactor{literals: []string{"my-literal-key"}, patterns: secretPatterns}
+	custom := &Redactor{patterns: append(append([]*regexp.Regexp{}, secretPatterns...),
+		regexp.MustCompile(`cust-\d+`), regexp.MustCompile(`(order=)\w+`))}
+	hosts := &Redactor{patterns: secretPatterns, hosts: true}
+	hosts.AddHostNames([]*Entity{{Name: "web-1"}, {Name: "web-10"}, {Name: "db"}})
+
+	tests := []struct {
+		name string
+		r    *Redactor
+		in   string
+		want string
+	}{
+		{"user key", base, "key NRAK-ABCDEFGHIJKLMNOPQRSTUV used", "key [REDACTED] used"},
+		{"ingest key", base, "NRII-abcdefghijklmnopqrstuvwxyz", "[REDACTED]"},
+		{"license key", base, "license 0123456789abcdef0123456789abcdef01234567 ok", "license [REDACTED] ok"},
+		{"eu license key", base, "0123456789abcdef0123456789abcdef0123NRAL", "[REDACTED]"},
+		{"bearer token", base, "Authorization: Bearer abc.def-123456", "Authorization: Bearer [REDACTED]"},
+		{"key=value keeps the key", base, "api_key=secret123&x=1", "api_key=[REDACTED]&x=1"},
+		{"json keeps the key", base, `{"password": "hunter22", "user": "bob"}`, `{"password": "[REDACTED]", "user": "bob"}`},
+		{"header", base, "X-Api-Key: abcdef", "X-Api-Key: [REDACTED]"},
+		{"configured key", base, "sent my-literal-key twice: my-literal-key", "sent [REDACTED] twice: [REDACTED]"},
+		{"plain text is untouched", base, "refresh took 1.5s for 12 hosts", "refresh took 1.5s for 12 hosts"},
+		{"short hex is untouched", base, "guid abcdef0123", "guid abcdef0123"},
+		{"hosts are kept without redact_hosts", base, "web-1 at 10.0.0.1", "web-1 at 10.0.0.1"},
+		{"custom pattern", custom, "customer cust-42 paged", "customer [REDACTED] paged"},
+		{"custom pattern keeps its first group", custom, "order=A123 shipped", "order=[REDACTED] shipped"},
+		{"host names", hosts, "web-1 is down", hostToken("web-1") + " is down"},
+		{"longest name first", hosts, "web-10 and web-1", hostToken("web-10") + " and " + hostToken("web-1")},
+		{"short names are not masked", hosts, "db restarted", "db restarted"},
+		{"ipv4", hosts, "from 192.168.1.20:443", "from [IP]:443"},
+		{"ipv6", hosts, "from fe80:0:0:0:1ff:fe23:4567:890a", "from [IP]"},
+		{"secrets and hosts together", hosts, "web-1 token=abc12345", hostToken("web-1") + " token=[REDACTED]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactHostTokens(t *testing.T) {
+	if hostToken("Web-1") != hostToken("web-1") {
+		t.Error("host tokens should not depend on case")
+	}
+	if hostToken("web-1") == hostToken("web-2") {
+		t.Error("different hosts got the same token")
+	}
+	if tok := hostToken("web-1"); !strings.HasPrefix(tok, "host-") || len(tok) != len("host-")+6 {
+		t.Errorf("hostToken = %q, want host- and 6 hex digits", tok)
+	}
+
+	// Names are only collected with redact_hosts on, and are remembered once seen
+	off := &Redactor{}
+	off.AddHostNames([]*Entity{{Name: "web-1"}})
+	if off.known != nil {
+		t.Error("host names were collected with redact_hosts off")
+	}
+	on := &Redactor{hosts: true}
+	on.AddHostNames([]*Entity{{Name: "web-1"}})
+	on.AddHostNames([]*Entity{{Name: "web-2"}})
+	if got := on.Redact("web-1 web-2"); strings.Contains(got, "web-") {
+		t.Errorf("Redact = %q, want both hosts masked", got)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	minRefreshInterval = 5 * time.Second
+	maxRefreshInterval = 10 * time.Minute
+	idleAfter          = 10 * time.Minute // no key presses for this long slows refreshes down
+	changingFor        = 5 * time.Minute  // alert transitions keep refreshes fast for this long
+)
+
+// RefreshScheduler decides when the next refresh is due: faster while alerts are active or
+// changing, slower while the terminal is idle, and backing off exponentially on API errors
+type RefreshScheduler struct {
+	mu            sync.Mutex
+	base          time.Duration
+	adaptive      bool
+	lastStart     time.Time
+	lastInput     time.Time // zero until the TUI reports input, so headless mode is never idle
+	failures      int
+	alerting      int
+	changingUntil time.Time
+	wake          chan struct{}
+}
+
+// NewRefreshScheduler schedules refreshes around base; with adaptive false base is always used
+func NewRefreshScheduler(base time.Duration, adaptive bool) *RefreshScheduler {
+	if base < minRefreshInterval {
+		base = minRefreshInterval
+	}
+	return &RefreshScheduler{
+		base:      base,
+		adaptive:  adaptive,
+		lastStart: time.Now(),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Interval returns the current delay between refreshes and a short reason for it
+func (s *RefreshScheduler) Interval() (time.Duration, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.interval()
+}
+
+func (s *RefreshScheduler) interval() (time.Duration, string) {
+	if !s.adaptive {
+		return s.base, ""
+	}
+	d, reason := s.base, ""
+	now := time.Now()
+	switch {
+	case s.failures > 0:
+		d, reason = s.base, "backoff"
+		for i := 0; i < s.failures && d < maxRefreshInterval; i++ {
+			d *= 2
+		}
+	case now.Before(s.changingUntil):
+		d, reason = s.base/4, "alerts changing"
+	case s.alerting > 0:
+		d, reason = s.base/2, "alerts active"
+	case !s.lastInput.IsZero() && now.Sub(s.lastInput) > idleAfter:
+		d, reason = s.base*4, "idle"
+	}
+	if d < minRefreshInterval {
+		d = minRefreshInterval
+	}
+	if d > maxRefreshInterval {
+		d = maxRefreshInterval
+	}
+	return d, reason
+}
+
+// Next returns when the next refresh is due
+func (s *RefreshScheduler) Next() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, _ := s.interval()
+	return s.lastStart.Add(d)
+}
+
+// Started records that a refresh began, from the schedule or started by hand
+func (s *RefreshScheduler) Started() {
+	s.mu.Lock()
+	s.lastStart = time.Now()
+	s.mu.Unlock()
+	s.poke()
+}
+
+// Activity records user input; leaving idle mode refreshes sooner
+func (s *RefreshScheduler) Activity() {
+	s.mu.Lock()
+	wasIdle := !s.lastInput.IsZero() && time.Since(s.lastInput) > idleAfter
+	s.lastInput = time.Now()
+	s.mu.Unlock()
+	if wasIdle {
+		s.poke()
+	}
+}
+
+// Failed records a refresh that could not reach New Relic
+func (s *RefreshScheduler) Failed() {
+	s.mu.Lock()
+	s.failures++
+	s.mu.Unlock()
+	s.poke()
+}
+
+// Succeeded records a completed refresh with its alerting count and number of transitions
+func (s *RefreshScheduler) Succeeded(alerting, changes int) {
+	s.mu.Lock()
+	s.failures = 0
+	s.alerting = alerting
+	if changes > 0 {
+		s.changingUntil = time.Now().Add(changingFor)
+	}
+	s.mu.Unlock()
+	s.poke()
+}
+
+// poke makes Run recompute its wait without blocking
+func (s *RefreshScheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run calls refresh whenever the next refresh is due, until ctx is cancelled
+func (s *RefreshScheduler) Run(ctx context.Context, refresh func()) {
+	for {
+		timer := time.NewTimer(time.Until(s.Next()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+		s.Started()
+		refresh()
+	}
+}
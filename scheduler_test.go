@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefreshSchedulerInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     time.Duration
+		adaptive bool
+		setup    func(s *RefreshScheduler)
+		want     time.Duration
+		reason   string
+	}{
+		{name: "base", base: time.Minute, adaptive: true, want: time.Minute},
+		{name: "base is clamped to the minimum", base: time.Second, adaptive: true, want: minRefreshInterval},
+		{
+			name: "not adaptive ignores alerts and failures", base: time.Minute,
+			setup: func(s *RefreshScheduler) { s.Failed(); s.Succeeded(3, 1); s.Failed() },
+			want:  time.Minute,
+		},
+		{
+			name: "alerts active", base: time.Minute, adaptive: true,
+			setup: func(s *RefreshScheduler) { s.Succeeded(2, 0) },
+			want:  30 * time.Second, reason: "alerts active",
+		},
+		{
+			name: "alerts changing", base: time.Minute, adaptive: true,
+			setup: func(s *RefreshScheduler) { s.Succeeded(2, 1) },
+			want:  15 * time.Second, reason: "alerts changing",
+		},
+		{
+			name: "alerts changing is floored at the minimum", base: 10 * time.Second, adaptive: true,
+			setup: func(s *RefreshScheduler) { s.Succeeded(1, 1) },
+			want:  minRefreshInterval, reason: "alerts changing",
+		},
+		{
+			name: "changes age out", base: time.Minute, adaptive: true,
+			setup: func(s *RefreshScheduler) {
+				s.Succeeded(1, 1)
+				s.changingUntil = time.Now().Add(-time.Second)
+			},
+			want: 30 * time.Second, reason: "alerts active",
+		},
+		{
+			name: "idle", base: time.Minute, adaptive: true,
+			setup: func(s *RefreshScheduler) { s.lastInput = time.Now().Add(-idleAfter - time.Second) },
+			want:  4 * time.Minute, reason: "idle",
+		},
+		{
+			name: "recent input is not idle", base: time.Minute, adaptive: true,
+			setup: func(s *RefreshScheduler) { s.Activity() },
+			want:  time.Minute,
+		},
+		{
+			name: "alerts beat idle", base: time.Minute, adaptive: true,
+			setup: func(s *RefreshScheduler) {
+				s.lastInput = time.Now().Add(-idleAfter - time.Second)
+				s.Succeeded(1, 0)
+			},
+			want: 30 * time.Second, reason: "alerts active",
+		},
+		{
+			name: "one failure doubles", base: time.Minute, adaptive: true,
+			setup: func(s *RefreshScheduler) { s.Failed() },
+			want:  2 * time.Minute, reason: "backoff",
+		},
+		{
+			name: "backoff is exponential", base: time.Minute, adaptive: true,
+			setup: func(s *RefreshScheduler) { s.Failed(); s.Failed(); s.Failed() },
+			want:  8 * time.Minute, reason: "backoff",
+		},
+		{
+			name: "backoff is capped", base: time.Minute, adaptive: true,
+			setup: func(s *RefreshScheduler) {
+				for i := 0; i < 100; i++ {
+					s.Failed()
+				}
+			},
+			want: maxRefreshInterval, reason: "backoff",
+		},
+		{
+			name: "backoff beats changing alerts", base: time.Minute, adaptive: true,
+			setup: func(s *RefreshScheduler) { s.Succeeded(1, 1); s.Failed() },
+			want:  2 * time.Minute, reason: "backoff",
+		},
+		{
+			name: "success resets backoff", base: time.Minute, adaptive: true,
+			setup: func(s *RefreshScheduler) { s.Failed(); s.Failed(); s.Succeeded(0, 0) },
+			want:  time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewRefreshScheduler(tt.base, tt.adaptive)
+			if tt.setup != nil {
+				tt.setup(s)
+			}
+			got, reason := s.Interval()
+			if got != tt.want || reason != tt.reason {
+				t.Errorf("Interval() = %v, %q; want %v, %q", got, reason, tt.want, tt.reason)
+			}
+		})
+	}
+}
+
+func TestRefreshSchedulerNext(t *testing.T) {
+	s := NewRefreshScheduler(time.Minute, true)
+	s.Started()
+	started := time.Now()
+	s.Failed()
+	if next := s.Next(); next.Before(started.Add(2*time.Minute-time.Second)) || next.After(started.Add(2*time.Minute+time.Second)) {
+		t.Errorf("Next() = %v after one failure, want about 2m after the last start", next.Sub(started))
+	}
+}
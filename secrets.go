@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// apiKeyCommandTimeout bounds api_key_cmd and keyring lookups, which may prompt to unlock
+const apiKeyCommandTimeout = 30 * time.Second
+
+// resolveAPIKey replaces the plaintext api_key with one from api_key_cmd, api_key_env or the
+// keyring, in that order of preference, when one of them is configured
+func resolveAPIKey(cfg *Config) error {
+	var key, source string
+	var err error
+	switch {
+	case cfg.APIKeyCmd != "":
+		source = "api_key_cmd"
+		key, err = runSecretCommand(func(ctx context.Context) *exec.Cmd {
+			return shellCommandContext(ctx, cfg.APIKeyCmd)
+		})
+	case cfg.APIKeyEnv != "":
+		source = "api_key_env"
+		if key = os.Getenv(cfg.APIKeyEnv); key == "" {
+			err = fmt.Errorf("environment variable %s is not set", cfg.APIKeyEnv)
+		}
+	case cfg.APIKeyKeyring:
+		source = "api_key_keyring"
+		key, err = keyringLookup(keyringAccount(cfg))
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	cfg.APIKey = strings.TrimSpace(key)
+	logFor("config").Debug("loaded API key", "source", source)
+	return nil
+}
+
+// keyringAccount is the "account" attribute the key is stored under in the keyring
+func keyringAccount(cfg *Config) string {
+	if cfg.AccountID != "" {
+		return cfg.AccountID
+	}
+	return "default"
+}
+
+// keyringLookup reads the key from the Secret Service keyring (GNOME Keyring, KWallet) via
+// secret-tool. Store it with:
+//
+//	secret-tool store --label="Osiris New Relic API key" service osiris account <account_id>
+func keyringLookup(account string) (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", fmt.Errorf("the Secret Service keyring is only supported on Linux")
+	}
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return "", fmt.Errorf("secret-tool not found (install libsecret-tools)")
+	}
+	return runSecretCommand(func(ctx context.Context) *exec.Cmd {
+		return exec.CommandContext(ctx, "secret-tool", "lookup", "service", "osiris", "account", account)
+	})
+}
+
+// shellCommandContext is shellCommand with a context, for commands that must not hang startup
+func shellCommandContext(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// runSecretCommand runs a command and returns its trimmed stdout. Stdin and stderr stay on the
+// terminal so tools like pass or op can prompt for a passphrase.
+func runSecretCommand(build func(ctx context.Context) *exec.Cmd) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiKeyCommandTimeout)
+	defer cancel()
+
+	cmd := build(ctx)
+	var stdout bytes.Buffer
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("timed out after %s", apiKeyCommandTimeout)
+		}
+		return "", err
+	}
+	key := strings.TrimSpace(stdout.String())
+	if key == "" {
+		return "", fmt.Errorf("command printed nothing")
+	}
+	// Only the first line counts, e.g. pass stores metadata on the following lines
+	if i := strings.IndexByte(key, '\n'); i >= 0 {
+		key = strings.TrimSpace(key[:i])
+	}
+	return key, nil
+}
+
+// configPermissionWarning returns a warning when the config file can be read by other users
+func configPermissionWarning(path string) string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	if mode := info.Mode().Perm(); mode&0044 != 0 {
+		return fmt.Sprintf("%s is readable by other users (mode %04o); run chmod 600 %s", path, mode, path)
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshot is one published view of the entity list. A snapshot and the entities it holds are
+// never modified once published: every update builds a new one and swaps it in, so readers on
+// any goroutine can use what Snapshot() returns without holding a lock.
+type Snapshot struct {
+	Entities       []*Entity
+	Error          string
+	IncidentsError string // alert state could not be fetched, so alert flags may be out of date
+	RefreshedAt    time.Time
+	Refreshing     bool
+	Stale          bool // entities come from the on-disk cache, not a live refresh
+}
+
+// Snapshot returns the current snapshot; never nil
+func (s *AppState) Snapshot() *Snapshot {
+	return s.snapshot.Load()
+}
+
+// update publishes a copy of the current snapshot with fn applied. fn may replace Entities
+// with a new slice but must not modify the existing one or the entities in it.
+func (s *AppState) update(fn func(next *Snapshot)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := *s.snapshot.Load()
+	fn(&next)
+	s.snapshot.Store(&next)
+}
+
+// beginRefresh starts a refresh and returns the context it must run under. When supersede is
+// set any running refresh is cancelled first, otherwise ok is false while one is still fetching
+// entities. Starting a refresh always cancels the incident stage of the previous one, whose
+// results would be older than the new refresh's anyway.
+func (s *AppState) beginRefresh(parent context.Context, supersede bool) (ctx context.Context, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.snapshot.Load().Refreshing && !supersede {
+		return nil, false
+	}
+	if s.cancelRefresh != nil {
+		s.cancelRefresh()
+	}
+	ctx, s.cancelRefresh = context.WithCancel(parent)
+	next := *s.snapshot.Load()
+	next.Refreshing = true
+	s.snapshot.Store(&next)
+	return ctx, true
+}
+
+// publish is update for a refresh running under ctx: nothing is published once ctx has been
+// cancelled, so an abandoned refresh can never overwrite newer data. It reports whether fn ran.
+func (s *AppState) publish(ctx context.Context, fn func(next *Snapshot)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ctx.Err() != nil {
+		return false
+	}
+	next := *s.snapshot.Load()
+	fn(&next)
+	s.snapshot.Store(&next)
+	return true
+}
+
+// Select records the highlighted entity by key so it survives reordering between refreshes
+func (s *AppState) Select(key string) {
+	s.mu.Lock()
+	s.selectedKey = key
+	s.mu.Unlock()
+}
+
+// Selected returns the highlighted entity from the current snapshot, or nil
+func (s *AppState) Selected() *Entity {
+	s.mu.Lock()
+	key := s.selectedKey
+	s.mu.Unlock()
+	if key == "" {
+		return nil
+	}
+	for _, e := range s.Snapshot().Entities {
+		if entityKey(e) == key {
+			return e
+		}
+	}
+	return nil
+}
+
+// cloneEntities copies entities so a refresh stage can set alert state without touching
+// entities that are already published. Tags maps are shared since they are never modified.
+func cloneEntities(entities []*Entity) []*Entity {
+	clones := make([]*Entity, len(entities))
+	for i, e := range entities {
+		c := *e
+		clones[i] = &c
+	}
+	return clones
+}
+
+// sortByAlert returns a new slice with alerting entities first, then by name
+func sortByAlert(entities []*Entity) []*Entity {
+	sorted := make([]*Entity, len(entities))
+	copy(sorted, entities)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].HasAlert == sorted[j].HasAlert {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].HasAlert && !sorted[j].HasAlert
+	})
+	return sorted
+}
+
+// findNextMatch returns the key of the next entity after the last match whose name contains
+// the search query, wrapping around, or "" if none does
+func findNextMatch(state *AppState) string {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	q := strings.ToLower(state.searchQuery)
+	if q == "" {
+		return ""
+	}
+	entities := state.snapshot.Load().Entities
+	start := 0
+	for i, e := range entities {
+		if entityKey(e) == state.lastSearchKey {
+			start = i + 1
+			break
+		}
+	}
+	n := len(entities)
+	for i := 0; i < n; i++ {
+		e := entities[(start+i)%n]
+		if strings.Contains(strings.ToLower(e.Name), q) {
+			state.lastSearchKey = entityKey(e)
+			return state.lastSearchKey
+		}
+	}
+	return ""
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testEntities(names ...string) []*Entity {
+	entities := make([]*Entity, len(names))
+	for i, name := range names {
+		entities[i] = &Entity{Name: name, GUID: "guid-" + name, Type: "HOST"}
+	}
+	return entities
+}
+
+func keysOf(entities []*Entity) []string {
+	keys := make([]string, len(entities))
+	for i, e := range entities {
+		keys[i] = entityKey(e)
+	}
+	return keys
+}
+
+// Run with -race: refreshes publishing while the UI selects and searches must not share
+// mutable state
+func TestConcurrentPublishAndSelection(t *testing.T) {
+	state := newAppState(defaultConfig())
+	state.searchQuery = "web"
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		w := w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				ctx, ok := state.beginRefresh(context.Background(), true)
+				if !ok {
+					t.Error("a superseding refresh must always start")
+					return
+				}
+				entities := testEntities(fmt.Sprintf("web-%d", (w+i)%5), "db-1", fmt.Sprintf("web-%d", (w+i+1)%5))
+				state.publish(ctx, func(next *Snapshot) {
+					next.Entities = sortByAlert(cloneEntities(entities))
+					next.Refreshing = false
+				})
+			}
+		}()
+	}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				state.Select(findNextMatch(state))
+				if e := state.Selected(); e != nil && !strings.HasPrefix(e.Name, "web-") {
+					t.Errorf("search selected %q, which doesn't match", e.Name)
+				}
+				for _, e := range state.Snapshot().Entities {
+					_ = e.Name + e.GUID
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestStalePublishIsDropped(t *testing.T) {
+	state := newAppState(defaultConfig())
+
+	old, ok := state.beginRefresh(context.Background(), false)
+	if !ok {
+		t.Fatal("first refresh did not start")
+	}
+	if _, ok := state.beginRefresh(context.Background(), false); ok {
+		t.Fatal("a timer refresh started while another was fetching entities")
+	}
+	current, ok := state.beginRefresh(context.Background(), true)
+	if !ok {
+		t.Fatal("a superseding refresh did not start")
+	}
+
+	if !state.publish(current, func(next *Snapshot) { next.Entities = testEntities("new") }) {
+		t.Fatal("publish for the current refresh was dropped")
+	}
+	if state.publish(old, func(next *Snapshot) { next.Entities = testEntities("old") }) {
+		t.Error("publish for a superseded refresh ran")
+	}
+	if got := keysOf(state.Snapshot().Entities); len(got) != 1 || got[0] != "guid-new" {
+		t.Errorf("snapshot entities = %v, want [guid-new]", got)
+	}
+
+	parent, cancel := context.WithCancel(context.Background())
+	ctx, _ := state.beginRefresh(parent, true)
+	cancel()
+	if state.publish(ctx, func(next *Snapshot) { next.Entities = nil }) {
+		t.Error("publish ran after the parent context was cancelled")
+	}
+}
+
+func TestSelectionFollowsEntityAcrossReorder(t *testing.T) {
+	state := newAppState(defaultConfig())
+	list := NewEntityListView()
+	var changes []string
+	list.OnChange(func(index int) {
+		state.Select(list.SelectedKey())
+		changes = append(changes, list.SelectedKey())
+	})
+
+	show := func(entities []*Entity) {
+		state.update(func(next *Snapshot) { next.Entities = entities })
+		list.Sync(entities)
+	}
+
+	show(testEntities("a", "b", "c"))
+	list.SelectKey("guid-b")
+	changes = nil
+
+	// c starts alerting and moves to the top, d appears, and b ends up last
+	reordered := testEntities("c", "a", "d", "b")
+	reordered[0].HasAlert = true
+	reordered[3].Flapping = true
+	show(reordered)
+
+	if got := list.SelectedKey(); got != "guid-b" {
+		t.Errorf("list selection = %q after reorder, want guid-b", got)
+	}
+	if got := keysOf(state.Snapshot().Entities); fmt.Sprint(list.keys) != fmt.Sprint(got) {
+		t.Errorf("list rows = %v, want %v", list.keys, got)
+	}
+	if e := state.Selected(); e == nil || e.Name != "b" || !e.Flapping {
+		t.Errorf("Selected() = %+v, want b from the new snapshot", e)
+	}
+	if len(changes) != 1 || changes[0] != "guid-b" {
+		t.Errorf("change handler saw %v, want a single [guid-b]", changes)
+	}
+
+	// When the selected entity disappears the row under the cursor is selected instead
+	show(testEntities("c", "a", "d"))
+	if e := state.Selected(); e == nil || list.SelectedKey() != entityKey(e) {
+		t.Errorf("Selected() = %+v, list selection = %q; want them to agree", e, list.SelectedKey())
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// flashDuration is how long a one-off message replaces the live status
+const flashDuration = 5 * time.Second
+
+// StatusBar is the one-line summary above the entity list: API health, account, counts,
+// active filter, data age and the next-refresh countdown. Call Refresh every second so the
+// age and countdown keep ticking.
+type StatusBar struct {
+	*tview.TextView
+	state      *AppState
+	config     *Config
+	flash      string
+	flashUntil time.Time
+}
+
+// NewStatusBar creates a status bar reading from state
+func NewStatusBar(state *AppState, config *Config) *StatusBar {
+	b := &StatusBar{
+		TextView: tview.NewTextView().SetDynamicColors(true),
+		state:    state,
+		config:   config,
+	}
+	b.SetBorder(false)
+	b.Refresh()
+	return b
+}
+
+// Flash shows msg in place of the live status for a few seconds; must run on the UI thread
+func (b *StatusBar) Flash(msg string) {
+	b.flash = msg
+	b.flashUntil = time.Now().Add(flashDuration)
+	b.SetText(msg)
+}
+
+// Refresh re-renders the status from the current snapshot; must run on the UI thread
+func (b *StatusBar) Refresh() {
+	if time.Now().Before(b.flashUntil) {
+		b.SetText(b.flash)
+		return
+	}
+	b.SetText(b.render())
+}
+
+func (b *StatusBar) render() string {
+	snap := b.state.Snapshot()
+	parts := make([]string, 0, 6)
+
+	parts = append(parts, apiHealth(snap))
+	if b.config.Demo {
+		parts = append(parts, "[yellow]demo[white]")
+	} else if b.config.AccountID != "" {
+		parts = append(parts, "acct "+tview.Escape(b.config.AccountID))
+	}
+
+	alerting, critical := 0, 0
+	for _, e := range snap.Entities {
+		if e.HasAlert {
+			alerting++
+			if e.Severity == "critical" {
+				critical++
+			}
+		}
+	}
+	counts := fmt.Sprintf("%d hosts", len(snap.Entities))
+	if alerting > 0 {
+		counts += fmt.Sprintf(" [red]%d alerting[white]", alerting)
+		if critical > 0 {
+			counts += fmt.Sprintf(" (%d critical)", critical)
+		}
+	}
+	if snap.Entities != nil {
+		parts = append(parts, counts)
+	}
+
+	if b.config.Filter != "" {
+		parts = append(parts, fmt.Sprintf("filter [yellow]%s[white]", tview.Escape(fmt.Sprintf("%q", b.config.Filter))))
+	}
+	if b.config.View != "" && b.config.View != "all" {
+		parts = append(parts, "view "+b.config.View)
+	}
+
+	switch {
+	case snap.Entities == nil && snap.Error == "":
+		parts = append(parts, "[yellow]⟳ Loading entities from New Relic...[white]")
+	case snap.Stale:
+		parts = append(parts, fmt.Sprintf("[yellow]cached %s old[white]", formatDuration(time.Since(snap.RefreshedAt))))
+	default:
+		parts = append(parts, fmt.Sprintf("updated %s ago", formatDuration(time.Since(snap.RefreshedAt))))
+	}
+
+	if snap.Refreshing {
+		parts = append(parts, "[yellow]⟳ refreshing...[white]")
+	} else {
+		parts = append(parts, refreshCountdown(b.state.scheduler))
+	}
+
+	status := strings.Join(parts, " [dim]│[white] ")
+	if snap.Error != "" {
+		status += fmt.Sprintf(" [red]✗ %s", tview.Escape(snap.Error))
+	} else if snap.Entities != nil && len(snap.Entities) == 0 && !snap.Refreshing {
+		status += " [dim]No entities found. Check API key and account ID."
+	}
+	return status
+}
+
+// apiHealth is a coloured indicator of whether the last refresh reached New Relic
+func apiHealth(snap *Snapshot) string {
+	switch {
+	case snap.Error != "":
+		return "[red]● API error[white]"
+	case snap.IncidentsError != "":
+		return "[yellow]● alerts unavailable[white]"
+	case snap.Stale && snap.Refreshing:
+		return "[yellow]● connecting[white]"
+	case snap.Stale:
+		return "[yellow]● offline[white]"
+	}
+	return "[green]● API ok[white]"
+}
+
+// refreshCountdown describes when the next scheduled refresh is due, and why if the
+// interval has been adapted
+func refreshCountdown(scheduler *RefreshScheduler) string {
+	secs := int(time.Until(scheduler.Next()).Seconds())
+	if secs < 0 {
+		secs = 0
+	}
+	text := fmt.Sprintf("next refresh in %ds", secs)
+	if _, reason := scheduler.Interval(); reason != "" {
+		text += " (" + reason + ")"
+	}
+	return text
+}
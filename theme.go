@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Theme holds the colors used for entity states, as tview color names or #rrggbb
+type Theme struct {
+	OK           string
+	Alert        string
+	Flapping     string
+	NotReporting string
+}
+
+// builtinThemes can be selected with theme = without defining them under [themes]
+var builtinThemes = map[string]Theme{
+	"default":    {OK: "green", Alert: "red", Flapping: "orange", NotReporting: "gray"},
+	"colorblind": {OK: "blue", Alert: "orange", Flapping: "fuchsia", NotReporting: "gray"},
+}
+
+// theme is the active theme, set from the config at startup
+var theme = builtinThemes["default"]
+
+// setThemeColor sets one [themes.NAME] key
+func (t *Theme) setThemeColor(key, color string) error {
+	if !validColor(color) {
+		return fmt.Errorf("unknown color %q (want a color name such as \"red\", or #rrggbb)", color)
+	}
+	switch key {
+	case "ok":
+		t.OK = color
+	case "alert":
+		t.Alert = color
+	case "flapping":
+		t.Flapping = color
+	case "not_reporting":
+		t.NotReporting = color
+	default:
+		return fmt.Errorf("unknown theme color %q (want ok, alert, flapping or not_reporting)", key)
+	}
+	return nil
+}
+
+func validColor(color string) bool {
+	if strings.HasPrefix(color, "#") {
+		return len(color) == 7 && tcell.GetColor(color) != tcell.ColorDefault
+	}
+	_, ok := tcell.ColorNames[strings.ToLower(color)]
+	return ok
+}
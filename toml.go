@@ -0,0 +1,424 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// config.toml is read with a small parser for the subset of TOML it needs: [table] headers
+// with dotted names, key = value pairs, basic and literal strings, integers, booleans and
+// arrays of those. Anything else is reported as an error rather than guessed at.
+
+type tomlKind int
+
+const (
+	tomlString tomlKind = iota
+	tomlInt
+	tomlBool
+	tomlArray
+)
+
+func (k tomlKind) String() string {
+	switch k {
+	case tomlString:
+		return "a string"
+	case tomlInt:
+		return "an integer"
+	case tomlBool:
+		return "a boolean"
+	}
+	return "an array"
+}
+
+type tomlValue struct {
+	kind tomlKind
+	str  string
+	num  int64
+	b    bool
+	arr  []tomlValue
+}
+
+// tomlEntry is one key = value and the table it appeared under
+type tomlEntry struct {
+	table []string // e.g. ["accounts", "prod"]; empty for top-level keys
+	key   string
+	value tomlValue
+	line  int
+}
+
+// tomlTable is a [table] header
+type tomlTable struct {
+	name []string
+	line int
+}
+
+type tomlDoc struct {
+	tables  []tomlTable
+	entries []tomlEntry
+}
+
+// ConfigError is a problem in a config file, reported as path:line: message
+type ConfigError struct {
+	Path string
+	Line int
+	Msg  string
+}
+
+func (e *ConfigError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Msg)
+	}
+	return e.Path + ": " + e.Msg
+}
+
+type tomlParser struct {
+	path   string
+	src    string
+	pos    int
+	line   int
+	table  []string
+	tables map[string]int // table name -> line of its header
+	keys   map[string]int // table-qualified key -> line it was set on
+	doc    tomlDoc
+}
+
+// parseTOML parses src, returning everything it could read and an error for each line it
+// couldn't, so one typo doesn't hide the rest
+func parseTOML(path, src string) (*tomlDoc, []error) {
+	p := &tomlParser{path: path, src: src, line: 1, tables: make(map[string]int), keys: make(map[string]int)}
+	var errs []error
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.src) {
+			break
+		}
+		var err error
+		if p.src[p.pos] == '[' {
+			err = p.parseHeader()
+		} else {
+			err = p.parseKeyValue()
+		}
+		if err == nil {
+			err = p.endOfLine()
+		}
+		if err != nil {
+			errs = append(errs, err)
+			p.skipLine()
+		}
+	}
+	return &p.doc, errs
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	return &ConfigError{Path: p.path, Line: p.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// skipBlank skips whitespace, newlines and comments
+func (p *tomlParser) skipBlank() {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\r':
+			p.pos++
+		case '\n':
+			p.pos++
+			p.line++
+		case '#':
+			p.skipLine()
+		default:
+			return
+		}
+	}
+}
+
+// skipSpace skips spaces and tabs on the current line
+func (p *tomlParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// skipLine moves to the end of the current line, leaving the newline for skipBlank
+func (p *tomlParser) skipLine() {
+	for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+		p.pos++
+	}
+}
+
+// endOfLine checks that nothing but a comment follows a header or value
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil
+	}
+	switch p.src[p.pos] {
+	case '#', '\r', '\n':
+		return nil
+	}
+	return p.errorf("unexpected %q after value", p.restOfLine())
+}
+
+func (p *tomlParser) restOfLine() string {
+	end := strings.IndexByte(p.src[p.pos:], '\n')
+	if end < 0 {
+		return strings.TrimSpace(p.src[p.pos:])
+	}
+	return strings.TrimSpace(p.src[p.pos : p.pos+end])
+}
+
+func (p *tomlParser) parseHeader() error {
+	line := p.line
+	p.pos++
+	if p.pos < len(p.src) && p.src[p.pos] == '[' {
+		return p.errorf("arrays of tables ([[...]]) are not supported")
+	}
+	name, err := p.parseKeyPath()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] != ']' {
+		return p.errorf("expected ] to close the table name")
+	}
+	p.pos++
+
+	joined := strings.Join(name, ".")
+	if prev, ok := p.tables[joined]; ok {
+		return p.errorf("table [%s] is already defined on line %d", joined, prev)
+	}
+	p.tables[joined] = line
+	p.table = name
+	p.doc.tables = append(p.doc.tables, tomlTable{name: name, line: line})
+	return nil
+}
+
+func (p *tomlParser) parseKeyValue() error {
+	line := p.line
+	path, err := p.parseKeyPath()
+	if err != nil {
+		return err
+	}
+	if len(path) > 1 {
+		return p.errorf("dotted key %q is not supported; use a [table] header", strings.Join(path, "."))
+	}
+	key := path[0]
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] != '=' {
+		return p.errorf("expected = after %q", key)
+	}
+	p.pos++
+	p.skipSpace()
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	full := strings.Join(append(append([]string(nil), p.table...), key), ".")
+	if prev, ok := p.keys[full]; ok {
+		return &ConfigError{Path: p.path, Line: line, Msg: fmt.Sprintf("%q is already set on line %d", key, prev)}
+	}
+	p.keys[full] = line
+	p.doc.entries = append(p.doc.entries, tomlEntry{table: p.table, key: key, value: value, line: line})
+	return nil
+}
+
+// parseKeyPath reads a bare, quoted or dotted key such as accounts."eu prod"
+func (p *tomlParser) parseKeyPath() ([]string, error) {
+	var path []string
+	for {
+		p.skipSpace()
+		part, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, part)
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] != '.' {
+			return path, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *tomlParser) parseKey() (string, error) {
+	if p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '"':
+			return p.parseBasicString()
+		case '\'':
+			return p.parseLiteralString()
+		}
+	}
+	start := p.pos
+	for p.pos < len(p.src) && isBareKeyChar(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("expected a key, found %q", p.restOfLine())
+	}
+	return p.src[start:p.pos], nil
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) parseValue() (tomlValue, error) {
+	if p.pos >= len(p.src) || p.src[p.pos] == '\n' || p.src[p.pos] == '\r' || p.src[p.pos] == '#' {
+		return tomlValue{}, p.errorf("missing value")
+	}
+	rest := p.src[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, `"""`), strings.HasPrefix(rest, `'''`):
+		err := p.errorf("multi-line strings are not supported")
+		// Skip to the closing quotes so the string's lines aren't read as keys
+		if end := strings.Index(rest[3:], rest[:3]); end >= 0 {
+			p.line += strings.Count(rest[:3+end], "\n")
+			p.pos += 3 + end + 3
+		}
+		return tomlValue{}, err
+	case rest[0] == '"':
+		s, err := p.parseBasicString()
+		return tomlValue{kind: tomlString, str: s}, err
+	case rest[0] == '\'':
+		s, err := p.parseLiteralString()
+		return tomlValue{kind: tomlString, str: s}, err
+	case rest[0] == '[':
+		return p.parseArray()
+	case rest[0] == '{':
+		return tomlValue{}, p.errorf("inline tables are not supported; use a [table] header")
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && (isBareKeyChar(p.src[p.pos]) || strings.IndexByte("+.:", p.src[p.pos]) >= 0) {
+		p.pos++
+	}
+	word := p.src[start:p.pos]
+	switch word {
+	case "true":
+		return tomlValue{kind: tomlBool, b: true}, nil
+	case "false":
+		return tomlValue{kind: tomlBool, b: false}, nil
+	case "":
+		return tomlValue{}, p.errorf("invalid value %q", p.restOfLine())
+	}
+	n, err := strconv.ParseInt(strings.ReplaceAll(word, "_", ""), 10, 64)
+	if err != nil {
+		return tomlValue{}, p.errorf("invalid value %q (strings must be quoted)", word)
+	}
+	return tomlValue{kind: tomlInt, num: n}, nil
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	var b strings.Builder
+	p.pos++
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch c {
+		case '\\':
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+			continue
+		case '\n':
+			return "", p.errorf("unterminated string")
+		case '"':
+			p.pos++
+			return b.String(), nil
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// parseEscape decodes the escape sequence at p.pos into b. Only TOML's escapes are accepted, not
+// Go's, and a backslash never swallows the end of the line: basic strings are single-line.
+func (p *tomlParser) parseEscape(b *strings.Builder) error {
+	if p.pos+1 >= len(p.src) || p.src[p.pos+1] == '\n' || p.src[p.pos+1] == '\r' {
+		return p.errorf("unterminated string")
+	}
+	c := p.src[p.pos+1]
+	p.pos += 2
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.src) {
+			return p.errorf("invalid escape sequence \\%c%s", c, p.src[p.pos:])
+		}
+		hex := p.src[p.pos : p.pos+n]
+		r, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return p.errorf("invalid escape sequence \\%c%s", c, hex)
+		}
+		b.WriteRune(rune(r))
+		p.pos += n
+	default:
+		return p.errorf("invalid escape sequence \\%c", c)
+	}
+	return nil
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	start := p.pos + 1
+	end := strings.IndexAny(p.src[start:], "'\n")
+	if end < 0 || p.src[start+end] != '\'' {
+		return "", p.errorf("unterminated string")
+	}
+	p.pos = start + end + 1
+	return p.src[start : start+end], nil
+}
+
+// parseArray reads an array of strings, integers or booleans, which may span several lines
+func (p *tomlParser) parseArray() (tomlValue, error) {
+	p.pos++
+	arr := tomlValue{kind: tomlArray}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.src) {
+			return tomlValue{}, p.errorf("unterminated array")
+		}
+		if p.src[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+		item, err := p.parseValue()
+		if err != nil {
+			return tomlValue{}, err
+		}
+		if item.kind == tomlArray {
+			return tomlValue{}, p.errorf("nested arrays are not supported")
+		}
+		if len(arr.arr) > 0 && item.kind != arr.arr[0].kind {
+			return tomlValue{}, p.errorf("array mixes %s and %s", arr.arr[0].kind, item.kind)
+		}
+		arr.arr = append(arr.arr, item)
+
+		p.skipBlank()
+		if p.pos < len(p.src) && p.src[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		if p.pos < len(p.src) && p.src[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+		return tomlValue{}, p.errorf("expected , or ] in array")
+	}
+}
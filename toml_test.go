@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseTOMLValues(t *testing.T) {
+	src := `# comment
+refresh_interval = 30
+filter = "web \"prod\"" # trailing comment
+log_file = 'C:\logs\osiris.log'
+adaptive_refresh = false
+notify = ["bell", 'desktop',]
+
+[accounts.prod]
+account_id = 1234567
+
+["views"."my view"]
+show = "alerting"
+`
+	doc, errs := parseTOML("config.toml", src)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	got := make([]string, 0, len(doc.entries))
+	for _, e := range doc.entries {
+		got = append(got, fmt.Sprintf("%d %s %s=%s", e.line, strings.Join(e.table, "."), e.key, tomlValueString(e.value)))
+	}
+	want := []string{
+		"2  refresh_interval=30",
+		`3  filter="web \"prod\""`,
+		`4  log_file="C:\\logs\\osiris.log"`,
+		"5  adaptive_refresh=false",
+		`6  notify=["bell" "desktop"]`,
+		"9 accounts.prod account_id=1234567",
+		`12 views.my view show="alerting"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("entries:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(doc.tables) != 2 || doc.tables[0].line != 8 || doc.tables[1].line != 11 {
+		t.Errorf("tables = %+v, want [accounts prod] on line 8 and [views my view] on line 11", doc.tables)
+	}
+}
+
+func TestParseTOMLEscapes(t *testing.T) {
+	doc, errs := parseTOML("config.toml", `filter = "\b\t\n\f\r\"\\ \u00e9\U0001F600"`+"\n")
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if got, want := doc.entries[0].value.str, "\b\t\n\f\r\"\\ é😀"; got != want {
+		t.Errorf("filter = %q, want %q", got, want)
+	}
+}
+
+func tomlValueString(v tomlValue) string {
+	switch v.kind {
+	case tomlString:
+		return fmt.Sprintf("%q", v.str)
+	case tomlInt:
+		return fmt.Sprint(v.num)
+	case tomlBool:
+		return fmt.Sprint(v.b)
+	}
+	items := make([]string, len(v.arr))
+	for i, item := range v.arr {
+		items[i] = tomlValueString(item)
+	}
+	return "[" + strings.Join(items, " ") + "]"
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string // path:line: message prefixes, in order
+	}{
+		{
+			name: "duplicate key",
+			src:  "filter = \"a\"\n\nfilter = \"b\"\n",
+			want: []string{`config.toml:3: "filter" is already set on line 1`},
+		},
+		{
+			name: "duplicate key in a table",
+			src:  "[accounts.prod]\naccount_id = 1\naccount_id = 2\n",
+			want: []string{`config.toml:3: "account_id" is already set on line 2`},
+		},
+		{
+			name: "same key in different tables",
+			src:  "[accounts.a]\naccount_id = 1\n[accounts.b]\naccount_id = 2\n",
+		},
+		{
+			name: "duplicate table",
+			src:  "[keybindings]\nquit = \"x\"\n\n[keybindings]\n",
+			want: []string{"config.toml:4: table [keybindings] is already defined on line 1"},
+		},
+		{
+			name: "array of tables",
+			src:  "[[accounts]]\n",
+			want: []string{"config.toml:1: arrays of tables ([[...]]) are not supported"},
+		},
+		{
+			name: "dotted key",
+			src:  "accounts.prod.account_id = 1\n",
+			want: []string{`config.toml:1: dotted key "accounts.prod.account_id" is not supported`},
+		},
+		{
+			name: "inline table",
+			src:  "\nprod = { account_id = 1 }\n",
+			want: []string{"config.toml:2: inline tables are not supported"},
+		},
+		{
+			name: "float",
+			src:  "refresh_interval = 1.5\n",
+			want: []string{`config.toml:1: invalid value "1.5"`},
+		},
+		{
+			name: "bare string",
+			src:  "filter = web\n",
+			want: []string{`config.toml:1: invalid value "web" (strings must be quoted)`},
+		},
+		{
+			name: "multi-line string",
+			src:  "filter = \"\"\"\nweb = 1\n\"\"\"\nview = two\n",
+			want: []string{
+				"config.toml:1: multi-line strings are not supported",
+				`config.toml:4: invalid value "two"`, // line numbers stay right after the skipped string
+			},
+		},
+		{
+			name: "mixed array",
+			src:  "notify = [\"bell\", 1]\n",
+			want: []string{"config.toml:1: array mixes a string and an integer"},
+		},
+		{
+			name: "nested array",
+			src:  "notify = [[\"bell\"]]\n",
+			want: []string{"config.toml:1: nested arrays are not supported"},
+		},
+		{
+			name: "unterminated string",
+			src:  "filter = \"web\n",
+			want: []string{"config.toml:1: unterminated string"},
+		},
+		{
+			name: "escapes only TOML knows",
+			src:  "a = \"\\x41\"\nb = \"\\101\"\nc = \"\\a\"\nd = \"\\uD800\"\ne = \"\\u12\"\n",
+			want: []string{
+				`config.toml:1: invalid escape sequence \x`,
+				`config.toml:2: invalid escape sequence \1`,
+				`config.toml:3: invalid escape sequence \a`,
+				`config.toml:4: invalid escape sequence \uD800`,
+				`config.toml:5: invalid escape sequence \u12`,
+			},
+		},
+		{
+			name: "backslash at the end of the line",
+			src:  "filter = \"web\\\nview = two\n",
+			want: []string{
+				"config.toml:1: unterminated string",
+				`config.toml:2: invalid value "two"`,
+			},
+		},
+		{
+			name: "missing value",
+			src:  "filter =\n",
+			want: []string{"config.toml:1: missing value"},
+		},
+		{
+			name: "junk after value",
+			src:  "refresh_interval = 30 seconds\n",
+			want: []string{`config.toml:1: unexpected "seconds" after value`},
+		},
+		{
+			name: "parsing continues after an error",
+			src:  "filter = web\nrefresh_interval = 1.5\nview = \"all\"\nview = \"all\"\n",
+			want: []string{
+				"config.toml:1: invalid value",
+				"config.toml:2: invalid value",
+				`config.toml:4: "view" is already set on line 3`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := parseTOML("config.toml", tt.src)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("got %d errors %v, want %d", len(errs), errs, len(tt.want))
+			}
+			for i, err := range errs {
+				if !strings.HasPrefix(err.Error(), tt.want[i]) {
+					t.Errorf("error %d = %q, want prefix %q", i, err, tt.want[i])
+				}
+			}
+		})
+	}
+}
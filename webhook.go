@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultWebhookTemplate renders the human-readable text used by chat payloads
+const defaultWebhookTemplate = `{{if eq .Event "alerting"}}🔴{{else}}✅{{end}} {{.Name}} {{.Event}}{{if .Condition}}: {{.Condition}}{{end}}{{if .Message}} ({{.Message}}){{end}}`
+
+// Webhook is a configured destination; Format is "generic", "slack" or "teams"
+type Webhook struct {
+	Format string
+	URL    string
+}
+
+// parseWebhook reads a `webhook=` value of the form "[format:]url"
+func parseWebhook(value string) (Webhook, error) {
+	for _, format := range []string{"generic", "slack", "teams"} {
+		if strings.HasPrefix(value, format+":") {
+			return Webhook{Format: format, URL: strings.TrimPrefix(value, format+":")}, nil
+		}
+	}
+	if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+		return Webhook{Format: "generic", URL: value}, nil
+	}
+	return Webhook{}, fmt.Errorf("webhook must be [generic|slack|teams:]http(s)://url, got %q", value)
+}
+
+// WebhookEvent is the data available to webhook templates and the generic JSON payload
+type WebhookEvent struct {
+	Event     string `json:"event"` // "alerting" or "resolved"
+	Name      string `json:"name"`
+	GUID      string `json:"guid"`
+	Severity  string `json:"severity"`
+	Condition string `json:"condition"`
+	Message   string `json:"message"`
+	Time      string `json:"timestamp"`
+	Text      string `json:"text"`
+}
+
+// WebhookForwarder posts alert transitions to the configured webhooks
+type WebhookForwarder struct {
+	hooks   []Webhook
+	tmpl    *template.Template
+	retries int
+	client  *http.Client
+}
+
+// NewWebhookForwarder builds a forwarder from the webhook* config keys
+func NewWebhookForwarder(config *Config) *WebhookForwarder {
+	w := &WebhookForwarder{
+		hooks:   config.Webhooks,
+		retries: config.WebhookRetries,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+
+	text := defaultWebhookTemplate
+	if config.WebhookTemplate != "" {
+		text = config.WebhookTemplate
+	}
+	tmpl, err := template.New("webhook").Parse(text)
+	if err != nil {
+		logFor("webhook").Warn("invalid webhook template, using default", "err", err)
+		tmpl = template.Must(template.New("webhook").Parse(defaultWebhookTemplate))
+	}
+	w.tmpl = tmpl
+	return w
+}
+
+// Forward sends every transition to every webhook in the background
+func (w *WebhookForwarder) Forward(transitions []AlertTransition) {
+	if len(w.hooks) == 0 || len(transitions) == 0 {
+		return
+	}
+
+	// Snapshot entity fields now; the entities may be replaced by the next refresh
+	events := make([]WebhookEvent, 0, len(transitions))
+	for _, t := range transitions {
+		ev := WebhookEvent{
+			Event: "resolved",
+			Name:  t.Entity.Name,
+			GUID:  t.Entity.GUID,
+			Time:  t.At.UTC().Format(time.RFC3339),
+		}
+		if t.Alerting {
+			ev.Event = "alerting"
+			ev.Severity = severityLabel(t.Entity)
+			ev.Condition = t.Entity.AlertType
+			ev.Message = t.Entity.AlertMessage
+		}
+		var text bytes.Buffer
+		if err := w.tmpl.Execute(&text, ev); err != nil {
+			logFor("webhook").Warn("webhook template failed", "err", err)
+			text.Reset()
+			text.WriteString(fmt.Sprintf("%s %s", ev.Name, ev.Event))
+		}
+		ev.Text = text.String()
+		events = append(events, ev)
+	}
+
+	go func() {
+		for _, ev := range events {
+			for _, hook := range w.hooks {
+				if err := w.send(hook, ev); err != nil {
+					logFor("webhook").Warn("webhook delivery failed", "format", hook.Format, "url", redactURL(hook.URL), "entity", ev.Name, "err", err)
+				}
+			}
+		}
+	}()
+}
+
+// webhookPayload shapes an event for the destination's expected format
+func webhookPayload(format string, ev WebhookEvent) interface{} {
+	switch format {
+	case "slack":
+		return map[string]interface{}{"text": ev.Text}
+	case "teams":
+		color := "2EB886"
+		if ev.Event == "alerting" {
+			color = "D40E0D"
+		}
+		return map[string]interface{}{
+			"@type":      "MessageCard",
+			"@context":   "http://schema.org/extensions",
+			"summary":    ev.Text,
+			"themeColor": color,
+			"title":      fmt.Sprintf("%s %s", ev.Name, ev.Event),
+			"text":       ev.Text,
+		}
+	}
+	return ev
+}
+
+// send posts one event, retrying with exponential backoff on network errors, 429 and 5xx
+func (w *WebhookForwarder) send(hook Webhook, ev WebhookEvent) error {
+	body, err := json.Marshal(webhookPayload(hook.Format, ev))
+	if err != nil {
+		return err
+	}
+
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err = w.post(hook.URL, body)
+		if err == nil {
+			logFor("webhook").Debug("webhook delivered", "format", hook.Format, "entity", ev.Name, "event", ev.Event)
+			return nil
+		}
+		if _, permanent := err.(permanentError); permanent || attempt >= w.retries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// permanentError marks failures that retrying cannot fix, such as a 4xx response
+type permanentError struct{ error }
+
+func (w *WebhookForwarder) post(url string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return permanentError{fmt.Errorf("status %d", resp.StatusCode)}
+}
+
+// redactURL drops the path of a webhook URL for logging, since chat webhooks embed their secret there
+func redactURL(url string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		if j := strings.Index(url[i+3:], "/"); j >= 0 {
+			return url[:i+3+j] + "/…"
+		}
+	}
+	return url
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// nrAccount is an account the API key can see
+type nrAccount struct {
+	ID   int
+	Name string
+}
+
+// fetchUserAccounts checks an API key by asking NerdGraph who it belongs to and which accounts
+// it can read
+func fetchUserAccounts(ctx context.Context, config *Config) (email string, accounts []nrAccount, err error) {
+	data, err := postNerdGraph(ctx, config, `{ actor { user { email } accounts { id name } } }`, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	actor, _ := data["actor"].(map[string]interface{})
+	if actor == nil {
+		return "", nil, fmt.Errorf("unexpected response from New Relic (is this a User key?)")
+	}
+	if user, ok := actor["user"].(map[string]interface{}); ok {
+		email, _ = user["email"].(string)
+	}
+	list, _ := actor["accounts"].([]interface{})
+	for _, item := range list {
+		a, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := a["id"].(float64)
+		name, _ := a["name"].(string)
+		accounts = append(accounts, nrAccount{ID: int(id), Name: name})
+	}
+	return email, accounts, nil
+}
+
+// SetupWizard is the first-run form shown when there is no config file: it checks an API key
+// and region against NerdGraph, lets the user pick accounts and writes the config
+type SetupWizard struct {
+	app      *tview.Application
+	pages    *tview.Pages
+	form     *tview.Form
+	status   *tview.TextView
+	accounts *tview.List
+	header   *tview.TextView
+	saveMsg  *tview.TextView
+
+	target  string
+	apiKey  string
+	region  string
+	found   []nrAccount
+	picked  []int // account IDs in the order they were picked
+	written string
+}
+
+// runSetupWizard runs the wizard in its own tview application and returns the path of the
+// config it wrote, or "" if the user skipped setup
+func runSetupWizard(target string) (string, error) {
+	w := &SetupWizard{app: tview.NewApplication(), target: target, region: "us"}
+
+	keyField := tview.NewInputField().SetLabel("User API key ").SetMaskCharacter('*').SetFieldWidth(48)
+	regions := []string{"US", "EU"}
+	w.form = tview.NewForm().
+		AddFormItem(keyField).
+		AddDropDown("Region       ", regions, 0, func(option string, index int) {
+			w.region = strings.ToLower(option)
+		}).
+		AddButton("Check key", func() {
+			w.check(strings.TrimSpace(keyField.GetText()))
+		}).
+		AddButton("Skip", w.app.Stop)
+	w.status = tview.NewTextView().SetDynamicColors(true).
+		SetText("[dim]Create a User key (NRAK-...) under API keys in New Relic. Skip starts with demo data.")
+
+	keyPage := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(w.form, 7, 0, true).
+		AddItem(w.status, 2, 0, false)
+	keyPage.SetBorder(true).SetTitle(" Osiris setup: API key ")
+
+	w.header = tview.NewTextView().SetDynamicColors(true)
+	w.accounts = tview.NewList().ShowSecondaryText(false)
+	w.saveMsg = tview.NewTextView().SetDynamicColors(true).
+		SetText("[dim]Space[-] pick | [dim]Enter[-] save | [dim]Esc[-] back")
+	accountPage := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(w.header, 2, 0, false).
+		AddItem(w.accounts, 0, 1, true).
+		AddItem(w.saveMsg, 1, 0, false)
+	accountPage.SetBorder(true).SetTitle(" Osiris setup: accounts ")
+
+	w.accounts.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEsc:
+			w.pages.SwitchToPage("key")
+			return nil
+		case event.Key() == tcell.KeyRune && event.Rune() == ' ':
+			w.toggle(w.accounts.GetCurrentItem())
+			return nil
+		}
+		return event
+	})
+	w.accounts.SetSelectedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
+		w.save(index)
+	})
+
+	w.pages = tview.NewPages().
+		AddPage("key", keyPage, true, true).
+		AddPage("accounts", accountPage, true, false)
+	if err := w.app.SetRoot(w.pages, true).Run(); err != nil {
+		return "", err
+	}
+	return w.written, nil
+}
+
+// check validates the key in the background and moves on to account selection
+func (w *SetupWizard) check(key string) {
+	if key == "" {
+		w.status.SetText("[red]✗ Enter an API key first")
+		return
+	}
+	w.status.SetText("[yellow]⟳ Checking the key with New Relic...")
+	region := w.region
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		email, accounts, err := fetchUserAccounts(ctx, &Config{APIKey: key, Region: region})
+		w.app.QueueUpdateDraw(func() {
+			switch {
+			case err != nil:
+				w.status.SetText(fmt.Sprintf("[red]✗ %s", tview.Escape(err.Error())))
+			case len(accounts) == 0:
+				w.status.SetText(fmt.Sprintf("[red]✗ The key works but can't see any accounts in the %s region", strings.ToUpper(region)))
+			default:
+				w.apiKey, w.found = key, accounts
+				w.picked = nil
+				w.header.SetText(fmt.Sprintf("[green]✓[white] Signed in as %s (%s). Pick the accounts to watch.",
+					tview.Escape(email), strings.ToUpper(region)))
+				w.renderAccounts()
+				w.pages.SwitchToPage("accounts")
+				w.app.SetFocus(w.accounts)
+			}
+		})
+	}()
+}
+
+func (w *SetupWizard) renderAccounts() {
+	current := w.accounts.GetCurrentItem()
+	w.accounts.Clear()
+	for _, a := range w.found {
+		mark := tview.Escape("[ ]")
+		if pickedIndex(w.picked, a.ID) >= 0 {
+			mark = "[green]" + tview.Escape("[x]") + "[white]"
+		}
+		w.accounts.AddItem(fmt.Sprintf("%s %s [dim](%d)", mark, tview.Escape(a.Name), a.ID), "", 0, nil)
+	}
+	w.accounts.SetCurrentItem(current)
+}
+
+func (w *SetupWizard) toggle(index int) {
+	if index < 0 || index >= len(w.found) {
+		return
+	}
+	id := w.found[index].ID
+	if i := pickedIndex(w.picked, id); i >= 0 {
+		w.picked = append(w.picked[:i], w.picked[i+1:]...)
+	} else {
+		w.picked = append(w.picked, id)
+	}
+	w.renderAccounts()
+}
+
+func pickedIndex(picked []int, id int) int {
+	for i, p := range picked {
+		if p == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes the picked accounts, or the highlighted one when none were picked
+func (w *SetupWizard) save(index int) {
+	var chosen []nrAccount
+	for _, id := range w.picked {
+		for _, a := range w.found {
+			if a.ID == id {
+				chosen = append(chosen, a)
+			}
+		}
+	}
+	if len(chosen) == 0 && index >= 0 && index < len(w.found) {
+		chosen = []nrAccount{w.found[index]}
+	}
+	path, err := writeSetupConfig(w.target, w.apiKey, w.region, chosen)
+	if err != nil {
+		w.saveMsg.SetText(fmt.Sprintf("[red]✗ %s", tview.Escape(err.Error())))
+		return
+	}
+	w.written = path
+	w.app.Stop()
+}
+
+// writeSetupConfig writes a new config file readable only by the user. One account fits the
+// key=value format at target; several need [accounts] tables, so they go to config.toml.
+func writeSetupConfig(target, apiKey, region string, accounts []nrAccount) (string, error) {
+	var b strings.Builder
+	b.WriteString("# Written by the osiris setup wizard; see the README for more settings\n")
+	path := target
+	if len(accounts) == 1 && !strings.HasSuffix(target, ".toml") {
+		fmt.Fprintf(&b, "api_key=%s\naccount_id=%d\nregion=%s\n", apiKey, accounts[0].ID, region)
+	} else {
+		path = strings.TrimSuffix(target, ".toml") + ".toml"
+		fmt.Fprintf(&b, "api_key = %s\nregion = %q\n", strconv.Quote(apiKey), region)
+		// All picked accounts are watched together; the tables let --account narrow to one
+		ids := make([]string, len(accounts))
+		for i, a := range accounts {
+			ids[i] = strconv.Itoa(a.ID)
+		}
+		fmt.Fprintf(&b, "account_id = %q\n", strings.Join(ids, ","))
+		names := accountTableNames(accounts)
+		for i, a := range accounts {
+			fmt.Fprintf(&b, "\n[accounts.%s]  # %s\naccount_id = %d\n", names[i], strings.ReplaceAll(a.Name, "\n", " "), a.ID)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	// O_EXCL: the wizard only runs without a config, and must never overwrite one
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// accountTableNames turns account names into unique bare TOML keys, e.g. "Acme Prod (EU)" -> acme-prod-eu
+func accountTableNames(accounts []nrAccount) []string {
+	names := make([]string, len(accounts))
+	used := make(map[string]bool)
+	for i, a := range accounts {
+		var b strings.Builder
+		for _, r := range strings.ToLower(a.Name) {
+			switch {
+			case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+				b.WriteRune(r)
+			case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+				b.WriteByte('-')
+			}
+		}
+		name := strings.TrimSuffix(b.String(), "-")
+		if name == "" || used[name] {
+			name = strings.TrimPrefix(name+"-"+strconv.Itoa(a.ID), "-")
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}